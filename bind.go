@@ -0,0 +1,257 @@
+package jsonrest
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valueLookup returns the raw string values available for the given name, and
+// whether the name was present at all.
+type valueLookup func(name string) ([]string, bool)
+
+// BindQuery populates the struct pointed to by v from the querystring, then
+// validates it. Fields are bound according to their `query:"name"` tags,
+// using the same conversions as typed endpoints:
+//
+//     var params struct {
+//         Limit  int      `query:"limit" default:"20"`
+//         Cursor *string  `query:"cursor"`
+//         IDs    []int    `query:"id"`
+//         Page   struct {
+//             Size int `query:"size"`
+//         } `query:"page."`
+//     }
+//
+// A `default` tag provides the value of an absent parameter, pointers are
+// left nil if the parameter is absent, and slices are read from repeated keys
+// as well as comma-separated values. The tag of a nested struct is prepended
+// to the names of its fields, so Page.Size above is bound from page.size.
+//
+// Conversion failures are reported as a 400 Bad Request HTTPError.
+func (r *Request) BindQuery(v interface{}) error {
+	if err := bindTagged(v, "query", "query", r.queryLookup()); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// BindHeaders populates the struct pointed to by v from the request headers,
+// then validates it. Fields are bound according to their `header:"Name"`
+// tags, as BindQuery does.
+func (r *Request) BindHeaders(v interface{}) error {
+	if err := bindTagged(v, "header", "header", r.headerLookup()); err != nil {
+		return err
+	}
+	return Validate(v)
+}
+
+// paramLookup returns a valueLookup for the URL parameters.
+func (r *Request) paramLookup() valueLookup {
+	return func(name string) ([]string, bool) {
+		for _, p := range r.params {
+			if p.Key == name {
+				return []string{p.Value}, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// queryLookup returns a valueLookup for the querystring.
+func (r *Request) queryLookup() valueLookup {
+	query := r.req.URL.Query()
+	return func(name string) ([]string, bool) {
+		values, ok := query[name]
+		return values, ok
+	}
+}
+
+// headerLookup returns a valueLookup for the request headers.
+func (r *Request) headerLookup() valueLookup {
+	return func(name string) ([]string, bool) {
+		values := r.req.Header.Values(name)
+		return values, len(values) > 0
+	}
+}
+
+// bindTagged populates the fields of the struct pointed to by dst that carry
+// the given struct tag, using lookup to find their raw values. The kind is
+// used in error messages to describe where the value came from, e.g. "query".
+func bindTagged(dst interface{}, tag, kind string, lookup valueLookup) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("jsonrest: bind %s: expected non-nil pointer, got %T", kind, dst)
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("jsonrest: bind %s: expected pointer to struct, got %T", kind, dst)
+	}
+	_, err := bindStruct(v, tag, kind, "", lookup)
+	return err
+}
+
+// bindStruct is the recursive implementation of bindTagged. The names of
+// fields are prefixed with prefix. It reports whether any field was set.
+func bindStruct(v reflect.Value, tag, kind, prefix string, lookup valueLookup) (bool, error) {
+	var set bool
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				ok, err := bindStruct(v.Field(i), tag, kind, prefix, lookup)
+				if err != nil {
+					return set, err
+				}
+				set = set || ok
+			}
+			continue
+		}
+		name = prefix + name
+		if isNestedStruct(field.Type) {
+			ok, err := bindNested(v.Field(i), tag, kind, name, lookup)
+			if err != nil {
+				return set, err
+			}
+			set = set || ok
+			continue
+		}
+		values, ok := lookup(name)
+		if !ok || len(values) == 0 {
+			def, ok := field.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			values = []string{def}
+		}
+		if isSlice(field.Type) {
+			values = splitList(values)
+		}
+		if err := setValue(v.Field(i), values); err != nil {
+			return set, BadRequest(fmt.Sprintf(
+				"invalid %s parameter: %s", kind, bindErrorDetails(name, values, field.Type),
+			)).Wrap(err)
+		}
+		set = true
+	}
+	return set, nil
+}
+
+// bindNested binds the fields of a nested struct, or pointer to struct, whose
+// names are prefixed with prefix. A nil pointer is only allocated if one of
+// its fields is set.
+func bindNested(v reflect.Value, tag, kind, prefix string, lookup valueLookup) (bool, error) {
+	if v.Kind() != reflect.Ptr {
+		return bindStruct(v, tag, kind, prefix, lookup)
+	}
+	elem := v
+	if v.IsNil() {
+		elem = reflect.New(v.Type().Elem())
+	}
+	set, err := bindNested(elem.Elem(), tag, kind, prefix, lookup)
+	if set && v.IsNil() {
+		v.Set(elem)
+	}
+	return set, err
+}
+
+// isNestedStruct reports whether t, or the type it points to, is a struct
+// whose fields are bound individually, rather than one converted from a
+// single value such as a time.Time.
+func isNestedStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(typeTextUnmarshaler)
+}
+
+// bindErrorDetails describes a failure to convert values into a field of type
+// t, in the same terms that jsonErrorDetails uses for request bodies.
+func bindErrorDetails(name string, values []string, t reflect.Type) string {
+	var typeSuffix string
+	if s := jsonType(t); s != "" {
+		typeSuffix = " (expected " + s + ")"
+	}
+	return fmt.Sprintf("cannot unmarshal %q to %q%s", strings.Join(values, ","), name, typeSuffix)
+}
+
+var typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setValue converts the raw values and stores them in v. Slices receive every
+// value; all other kinds receive the first.
+func setValue(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), values)
+	}
+	if reflect.PtrTo(v.Type()).Implements(typeTextUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+	if v.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, val := range values {
+			if err := setValue(s.Index(i), []string{val}); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setString(v, values[0])
+}
+
+// setString parses s according to the kind of v and stores the result.
+func setString(v reflect.Value, s string) error {
+	if v.Type() == typeTimeDuration {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
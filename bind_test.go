@@ -0,0 +1,149 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type listParams struct {
+	Limit  int      `query:"limit" default:"20"`
+	Cursor *string  `query:"cursor"`
+	IDs    []int    `query:"id"`
+	Fields []string `query:"fields" default:"id,name"`
+	Page   struct {
+		Size   int `query:"size"`
+		Number int `query:"number" default:"1"`
+	} `query:"page."`
+	Filter *struct {
+		Name string `query:"name"`
+	} `query:"filter."`
+}
+
+type clientHeaders struct {
+	Version   string        `header:"X-Client-Version" validate:"required"`
+	Timeout   time.Duration `header:"X-Timeout" default:"30s"`
+	Languages []string      `header:"Accept-Language"`
+	Trace     *struct {
+		ID string `header:"Id"`
+	} `header:"X-Trace-"`
+}
+
+func TestBindQuery(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/users", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var params listParams
+		if err := req.BindQuery(&params); err != nil {
+			return nil, err
+		}
+		return params, nil
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"Limit":  20,
+			"Cursor": nil,
+			"IDs":    nil,
+			"Fields": []string{"id", "name"},
+			"Page":   m{"Size": 0, "Number": 1},
+			"Filter": nil,
+		})
+	})
+
+	t.Run("values", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users?limit=5&cursor=abc&id=1,2&id=3&fields=email&page.size=10&filter.name=bob", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"Limit":  5,
+			"Cursor": "abc",
+			"IDs":    []int{1, 2, 3},
+			"Fields": []string{"email"},
+			"Page":   m{"Size": 10, "Number": 1},
+			"Filter": m{"Name": "bob"},
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users?page.size=big", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `invalid query parameter: cannot unmarshal "big" to "page.size" (expected integer)`,
+			},
+		})
+
+		w = do(r, http.MethodGet, "/users?id=1,x", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `invalid query parameter: cannot unmarshal "1,x" to "id" (expected array)`,
+			},
+		})
+	})
+}
+
+func TestBindHeaders(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var headers clientHeaders
+		if err := req.BindHeaders(&headers); err != nil {
+			return nil, err
+		}
+		return jsonrest.M{
+			"version":   headers.Version,
+			"timeout":   headers.Timeout.String(),
+			"languages": headers.Languages,
+			"trace":     headers.Trace,
+		}, nil
+	})
+	send := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Add(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("values", func(t *testing.T) {
+		w := send(
+			"X-Client-Version", "1.2.3",
+			"Accept-Language", "en, fr",
+			"Accept-Language", "de",
+			"X-Trace-Id", "abc",
+		)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"version":   "1.2.3",
+			"timeout":   "30s",
+			"languages": []string{"en", "fr", "de"},
+			"trace":     m{"ID": "abc"},
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		w := send("X-Client-Version", "1.2.3", "X-Timeout", "forever")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `invalid header parameter: cannot unmarshal "forever" to "X-Timeout" (expected duration)`,
+			},
+		})
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		w := send()
+		assert.Equal(t, w.Result().StatusCode, 422)
+	})
+}
@@ -0,0 +1,357 @@
+package jsonrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Codec encodes response values to, and decodes request bodies from, a
+// particular media type.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// JSONCodec is a Codec for application/json. Unless configured otherwise with
+// WithJSONCodec, every Router uses DefaultJSONCodec.
+type JSONCodec struct {
+	// Indent is the string used for each level of indentation when encoding.
+	// If empty, values are encoded compactly.
+	Indent string
+
+	// EscapeHTML indicates if problematic HTML characters should be escaped
+	// inside JSON strings when encoding.
+	EscapeHTML bool
+
+	// NewEncoder returns the encoder used to write values to w. If nil, an
+	// encoding/json Encoder is used. It allows the use of a faster,
+	// compatible JSON library.
+	NewEncoder func(w io.Writer) JSONEncoder
+
+	// DisallowUnknownFields causes bodies with object keys that do not match
+	// any field of the destination struct to be rejected.
+	DisallowUnknownFields bool
+
+	// DisallowTrailingData causes bodies with data after the first JSON value
+	// to be rejected.
+	DisallowTrailingData bool
+
+	// UseNumber causes numbers decoded into an interface{}, such as the
+	// values of M, to be decoded as json.Number rather than float64, so that
+	// no precision is lost.
+	UseNumber bool
+
+	// DisallowDuplicateKeys causes bodies with objects containing the same
+	// key more than once to be rejected.
+	DisallowDuplicateKeys bool
+}
+
+// A JSONEncoder writes JSON values to an output stream. It is implemented by
+// *json.Encoder, as well as by the encoders of most third-party JSON
+// libraries.
+type JSONEncoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+	SetEscapeHTML(on bool)
+}
+
+// DefaultJSONCodec is the JSONCodec used when none is configured. It encodes
+// values indented by two spaces.
+var DefaultJSONCodec = JSONCodec{Indent: "  ", EscapeHTML: true}
+
+// Encode implements the Codec interface.
+func (c JSONCodec) Encode(w io.Writer, v interface{}) error {
+	var enc JSONEncoder
+	if c.NewEncoder != nil {
+		enc = c.NewEncoder(w)
+	} else {
+		enc = json.NewEncoder(w)
+	}
+	if c.Indent != "" {
+		enc.SetIndent("", c.Indent)
+	}
+	enc.SetEscapeHTML(c.EscapeHTML)
+	return enc.Encode(v)
+}
+
+// Decode implements the Codec interface.
+func (c JSONCodec) Decode(r io.Reader, v interface{}) error {
+	if c.DisallowUnknownFields || c.DisallowDuplicateKeys {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		err = checkJSON(data, reflect.TypeOf(v), c.DisallowUnknownFields, c.DisallowDuplicateKeys)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	dec := json.NewDecoder(r)
+	if c.UseNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if c.DisallowTrailingData {
+		offset := dec.InputOffset()
+		if _, err := dec.Token(); err != io.EOF {
+			return &strictJSONError{Offset: offset, Code: "trailing_data", Msg: "unexpected data after top-level value"}
+		}
+	}
+	return nil
+}
+
+// A DecodeOption configures how JSON request bodies are decoded. Options may
+// be given for a single call to BindBody, or for every request with
+// WithDecodeOptions.
+type DecodeOption func(*JSONCodec)
+
+// DisallowUnknownFields is a DecodeOption that rejects bodies with fields
+// unknown to the destination struct.
+func DisallowUnknownFields() DecodeOption {
+	return func(c *JSONCodec) {
+		c.DisallowUnknownFields = true
+	}
+}
+
+// DisallowTrailingData is a DecodeOption that rejects bodies with data after
+// the first JSON value.
+func DisallowTrailingData() DecodeOption {
+	return func(c *JSONCodec) {
+		c.DisallowTrailingData = true
+	}
+}
+
+// UseNumber is a DecodeOption that decodes numbers into interface{} values as
+// json.Number rather than float64.
+func UseNumber() DecodeOption {
+	return func(c *JSONCodec) {
+		c.UseNumber = true
+	}
+}
+
+// DisallowDuplicateKeys is a DecodeOption that rejects bodies with objects
+// containing the same key more than once.
+func DisallowDuplicateKeys() DecodeOption {
+	return func(c *JSONCodec) {
+		c.DisallowDuplicateKeys = true
+	}
+}
+
+// WithDecodeOptions is an Option available for NewRouter to configure how
+// JSON request bodies are decoded for every request. The options are applied
+// to the JSON codec when a body is decoded, whether it is given before or
+// after WithJSONCodec, and before those given to BindBody.
+func WithDecodeOptions(opts ...DecodeOption) Option {
+	return func(r *Router) {
+		r.decodeOptions = append(r.decodeOptions, opts...)
+	}
+}
+
+// XMLCodec is a Codec for application/xml, using encoding/xml. Note that
+// encoding/xml cannot encode maps, such as M.
+type XMLCodec struct{}
+
+// Encode implements the Codec interface.
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// Decode implements the Codec interface.
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+const (
+	// defaultMediaType is the media type used when the client expresses no
+	// preference.
+	defaultMediaType = "application/json"
+
+	// jsonContentType is the content type of JSON responses.
+	jsonContentType = "application/json; charset=utf-8"
+)
+
+// WithCodec is an Option available for NewRouter to register a Codec for the
+// given media type, e.g. "application/msgpack". Request bodies are decoded
+// with the codec matching their Content-Type, and responses are encoded with
+// the codec that best matches the Accept header.
+func WithCodec(mediaType string, c Codec) Option {
+	return func(r *Router) {
+		r.codecs[mediaType] = c
+	}
+}
+
+// WithJSONCodec is an Option available for NewRouter to configure how JSON
+// request and response bodies are handled. For example, to encode responses
+// compactly:
+//
+//     jsonrest.NewRouter(jsonrest.WithJSONCodec(jsonrest.JSONCodec{EscapeHTML: true}))
+func WithJSONCodec(c JSONCodec) Option {
+	return WithCodec(defaultMediaType, c)
+}
+
+// lookupCodec returns the codec registered for the media type. Structured
+// syntax suffixes are honoured, so application/vnd.api+json is handled by the
+// application/json codec.
+func (r *Router) lookupCodec(mediaType string) (Codec, bool) {
+	if c, ok := r.codecs[mediaType]; ok {
+		return c, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		c, ok := r.codecs["application/"+mediaType[i+1:]]
+		return c, ok
+	}
+	return nil, false
+}
+
+// requestCodec returns the codec for the request body, according to its
+// Content-Type. Bodies without a Content-Type, or with one of the
+// lenientMediaTypes, are decoded as JSON.
+func (r *Router) requestCodec(req *http.Request) (Codec, error) {
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return r.codecs[defaultMediaType], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, BadRequest("malformed content-type header").Wrap(err)
+	}
+	c, ok := r.lookupCodec(mediaType)
+	if !ok && lenientMediaTypes[mediaType] {
+		c, ok = r.codecs[defaultMediaType], true
+	}
+	if !ok {
+		return nil, UnsupportedMediaType("unsupported content-type: " + mediaType)
+	}
+	return c, nil
+}
+
+// lenientMediaTypes are the media types that are decoded as JSON unless a
+// codec is registered for them, as clients such as curl -d send JSON bodies
+// with them.
+var lenientMediaTypes = map[string]bool{
+	"text/plain":                        true,
+	"application/x-www-form-urlencoded": true,
+}
+
+// responseCodec negotiates the media type and codec for the response,
+// according to the Accept header. It returns false if none of the acceptable
+// media types has a registered codec.
+func (r *Router) responseCodec(req *http.Request) (string, Codec, bool) {
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return defaultMediaType, r.codecs[defaultMediaType], true
+	}
+	for _, mediaRange := range parseAccept(accept) {
+		switch {
+		case mediaRange == "*/*":
+			return defaultMediaType, r.codecs[defaultMediaType], true
+		case strings.HasSuffix(mediaRange, "/*"):
+			prefix := strings.TrimSuffix(mediaRange, "*")
+			if strings.HasPrefix(defaultMediaType, prefix) {
+				return defaultMediaType, r.codecs[defaultMediaType], true
+			}
+			for _, mediaType := range r.mediaTypes() {
+				if strings.HasPrefix(mediaType, prefix) {
+					return mediaType, r.codecs[mediaType], true
+				}
+			}
+		default:
+			if c, ok := r.lookupCodec(mediaRange); ok {
+				return mediaRange, c, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// mediaTypes returns the media types with registered codecs, in sorted
+// order.
+func (r *Router) mediaTypes() []string {
+	mediaTypes := make([]string, 0, len(r.codecs))
+	for mediaType := range r.codecs {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// parseAccept returns the media ranges of an Accept header that have a
+// non-zero quality, most preferred first.
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	mediaTypes := make([]string, len(ranges))
+	for i, r := range ranges {
+		mediaTypes[i] = r.mediaType
+	}
+	return mediaTypes
+}
+
+// prettyIndent is the indentation used for JSON responses to requests with a
+// pretty query parameter.
+const prettyIndent = "  "
+
+// prettyCodec returns a codec that encodes indented JSON if c is a JSONCodec
+// and the request has a pretty query parameter, e.g. ?pretty or ?pretty=true,
+// which is useful for debugging. Otherwise, it returns c.
+func prettyCodec(c Codec, req *http.Request) Codec {
+	jc, ok := c.(JSONCodec)
+	if !ok {
+		return c
+	}
+	values, ok := req.URL.Query()["pretty"]
+	if !ok {
+		return c
+	}
+	if values[0] != "" {
+		if pretty, err := strconv.ParseBool(values[0]); err != nil || !pretty {
+			return c
+		}
+	}
+	jc.Indent = prettyIndent
+	return jc
+}
+
+// contentType returns the Content-Type header for a response body encoded
+// with the codec negotiated for mediaType.
+func contentType(mediaType string, c Codec, body interface{}) string {
+	if _, ok := c.(JSONCodec); !ok {
+		return mediaType
+	}
+	if _, ok := body.(*Problem); ok {
+		return problemContentType
+	}
+	return jsonContentType
+}
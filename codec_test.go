@@ -0,0 +1,322 @@
+package jsonrest_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+// csvCodec encodes and decodes a single line of comma-separated values.
+type csvCodec struct{}
+
+func (csvCodec) Encode(w io.Writer, v interface{}) error {
+	_, err := fmt.Fprintln(w, strings.Join(v.([]string), ","))
+	return err
+}
+
+func (csvCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*(v.(*[]string)) = strings.Split(strings.TrimSpace(string(b)), ",")
+	return nil
+}
+
+type codecItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func TestCodecs(t *testing.T) {
+	r := jsonrest.NewRouter(
+		jsonrest.WithCodec("application/xml", jsonrest.XMLCodec{}),
+		jsonrest.WithCodec("text/csv", csvCodec{}),
+	)
+	r.Get("/item", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return codecItem{Name: "widget"}, nil
+	})
+	r.Post("/item", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var item struct {
+			Name string `json:"name" validate:"min=2"`
+		}
+		if err := r.BindBody(&item); err != nil {
+			return nil, err
+		}
+		return codecItem{Name: item.Name}, nil
+	})
+	r.Post("/echo", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var values []string
+		if err := r.BindBody(&values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	})
+
+	send := func(method, path, body, contentType, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("default", func(t *testing.T) {
+		w := send(http.MethodGet, "/item", "", "", "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+		assert.JSONEqual(t, w.Body.String(), m{"name": "widget"})
+	})
+
+	t.Run("accept xml", func(t *testing.T) {
+		w := send(http.MethodGet, "/item", "", "", "text/html;q=0.9, application/xml")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/xml")
+		assert.Equal(t, w.Body.String(), "<item><name>widget</name></item>")
+	})
+
+	t.Run("accept wildcard", func(t *testing.T) {
+		w := send(http.MethodGet, "/item", "", "", "text/html, */*;q=0.1")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+	})
+
+	t.Run("decode and encode with custom codec", func(t *testing.T) {
+		w := send(http.MethodPost, "/echo", "a,b,c", "text/csv", "text/*")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Content-Type"), "text/csv")
+		assert.Equal(t, w.Body.String(), "a,b,c\n")
+	})
+
+	t.Run("structured syntax suffix", func(t *testing.T) {
+		w := send(http.MethodPost, "/echo", `["a"]`, "application/vnd.api+json", "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), []string{"a"})
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := send(http.MethodGet, "/item", "", "", "image/png")
+		assert.Equal(t, w.Result().StatusCode, 406)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "not_acceptable",
+				"message": "no acceptable media type",
+			},
+		})
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		w := send(http.MethodPost, "/echo", "a", "application/pdf", "")
+		assert.Equal(t, w.Result().StatusCode, 415)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "unsupported_media_type",
+				"message": "unsupported content-type: application/pdf",
+			},
+		})
+	})
+
+	t.Run("json sent as text or form", func(t *testing.T) {
+		for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded"} {
+			w := send(http.MethodPost, "/echo", `["a"]`, contentType, "")
+			assert.Equal(t, w.Result().StatusCode, 200)
+			assert.JSONEqual(t, w.Body.String(), []string{"a"})
+		}
+	})
+
+	t.Run("errors are not subject to accept", func(t *testing.T) {
+		w := send(http.MethodGet, "/unknown", "", "", "text/html")
+		assert.Equal(t, w.Result().StatusCode, 404)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+
+		w = send(http.MethodDelete, "/item", "", "", "text/html")
+		assert.Equal(t, w.Result().StatusCode, 405)
+	})
+
+	t.Run("errors are sent as json", func(t *testing.T) {
+		w := send(http.MethodPost, "/item", `{}`, "application/json", "application/xml")
+		assert.Equal(t, w.Result().StatusCode, 422)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "unprocessable_entity",
+				"message": "validation failed",
+				"details": []string{`"name" must have length at least 2`},
+				"fields": []m{
+					{"field": "name", "code": "min", "message": "must have length at least 2", "meta": m{"min": "2"}},
+				},
+			},
+		})
+
+		w = send(http.MethodGet, "/unknown", "", "", "application/xml")
+		assert.Equal(t, w.Result().StatusCode, 404)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+	})
+}
+
+// countingEncoder wraps a json.Encoder, counting the values it encodes.
+type countingEncoder struct {
+	*json.Encoder
+	count *int
+}
+
+func (e countingEncoder) Encode(v interface{}) error {
+	*e.count++
+	return e.Encoder.Encode(v)
+}
+
+func TestJSONCodec(t *testing.T) {
+	endpoint := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"html": "<b>"}, nil
+	}
+
+	t.Run("default", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Get("/", endpoint)
+		w := do(r, http.MethodGet, "/", nil, "")
+		assert.Equal(t, w.Body.String(), "{\n  \"html\": \"\\u003cb\\u003e\"\n}\n")
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		r := jsonrest.NewRouter(jsonrest.WithJSONCodec(jsonrest.JSONCodec{}))
+		r.Get("/", endpoint)
+		w := do(r, http.MethodGet, "/", nil, "")
+		assert.Equal(t, w.Body.String(), "{\"html\":\"<b>\"}\n")
+	})
+
+	t.Run("pretty", func(t *testing.T) {
+		r := jsonrest.NewRouter(jsonrest.WithJSONCodec(jsonrest.JSONCodec{}))
+		r.Get("/", endpoint)
+		w := do(r, http.MethodGet, "/?pretty", nil, "")
+		assert.Equal(t, w.Body.String(), "{\n  \"html\": \"<b>\"\n}\n")
+		w = do(r, http.MethodGet, "/?pretty=false", nil, "")
+		assert.Equal(t, w.Body.String(), "{\"html\":\"<b>\"}\n")
+	})
+
+	t.Run("custom encoder", func(t *testing.T) {
+		var count int
+		r := jsonrest.NewRouter(jsonrest.WithJSONCodec(jsonrest.JSONCodec{
+			NewEncoder: func(w io.Writer) jsonrest.JSONEncoder {
+				return countingEncoder{json.NewEncoder(w), &count}
+			},
+		}))
+		r.Get("/", endpoint)
+		w := do(r, http.MethodGet, "/", nil, "")
+		assert.Equal(t, w.Body.String(), "{\"html\":\"<b>\"}\n")
+		assert.Equal(t, count, 1)
+	})
+}
+
+func TestStrictDecoding(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+	}
+	type user struct {
+		Name      string    `json:"name"`
+		Addresses []address `json:"addresses"`
+		Labels    map[string]address
+	}
+	endpoint := func(opts ...jsonrest.DecodeOption) jsonrest.Endpoint {
+		return func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			var u user
+			if err := r.BindBody(&u, opts...); err != nil {
+				return nil, err
+			}
+			return u, nil
+		}
+	}
+	r := jsonrest.NewRouter()
+	r.Post("/lenient", endpoint())
+	r.Post("/unknown", endpoint(jsonrest.DisallowUnknownFields()))
+	r.Post("/trailing", endpoint(jsonrest.DisallowTrailingData()))
+	r.Post("/duplicates", endpoint(jsonrest.DisallowDuplicateKeys()))
+	r.Post("/number", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var v jsonrest.M
+		if err := r.BindBody(&v, jsonrest.UseNumber()); err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"type": fmt.Sprintf("%T", v["id"]), "id": v["id"]}, nil
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/lenient", strings.NewReader(`{"name": "a", "name": "b", "age": 1} garbage`), "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		body := `{"name": "a", "labels": {"home": {"city": "x"}}, "addresses": [{"city": "x"}, {"zip": "y"}]}`
+		w := do(r, http.MethodPost, "/unknown", strings.NewReader(body), "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `malformed or unexpected json: offset 84: unknown field "addresses[1].zip"`,
+				"fields": []m{
+					{"field": "addresses[1].zip", "code": "unknown_field", "message": "unknown field"},
+				},
+			},
+		})
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/trailing", strings.NewReader("{\"name\": \"a\"}\n"), "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+
+		w = do(r, http.MethodPost, "/trailing", strings.NewReader(`{"name": "a"} {}`), "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": "malformed or unexpected json: offset 13: unexpected data after top-level value",
+			},
+		})
+	})
+
+	t.Run("duplicate keys", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/duplicates", strings.NewReader(`{"Labels": {"a": {}, "a": {}}}`), "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `malformed or unexpected json: offset 24: duplicate key "Labels.a"`,
+				"fields": []m{
+					{"field": "Labels.a", "code": "duplicate_key", "message": "duplicate key"},
+				},
+			},
+		})
+	})
+
+	t.Run("use number", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/number", strings.NewReader(`{"id": 12345678901234567890}`), "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Body.String(), "{\n  \"id\": 12345678901234567890,\n  \"type\": \"json.Number\"\n}\n")
+	})
+
+	t.Run("router options", func(t *testing.T) {
+		r := jsonrest.NewRouter(jsonrest.WithDecodeOptions(jsonrest.DisallowUnknownFields()))
+		r.Post("/", endpoint())
+		w := do(r, http.MethodPost, "/", strings.NewReader(`{"age": 1}`), "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+	})
+
+	t.Run("router options before json codec", func(t *testing.T) {
+		r := jsonrest.NewRouter(
+			jsonrest.WithDecodeOptions(jsonrest.DisallowUnknownFields()),
+			jsonrest.WithJSONCodec(jsonrest.JSONCodec{}),
+		)
+		r.Post("/", endpoint())
+		w := do(r, http.MethodPost, "/", strings.NewReader(`{"age": 1}`), "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+	})
+}
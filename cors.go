@@ -0,0 +1,213 @@
+package jsonrest
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures Cross-Origin Resource Sharing for a Router created with
+// WithCORS.
+type CORS struct {
+	// AllowedOrigins lists the origins that may make cross-origin requests,
+	// e.g. "https://example.com". An origin may contain a "*" wildcard, as in
+	// "https://*.example.com", and "*" on its own allows any origin.
+	AllowedOrigins []string
+
+	// AllowedOriginPatterns lists regular expressions matching further
+	// origins that may make cross-origin requests.
+	AllowedOriginPatterns []*regexp.Regexp
+
+	// AllowedMethods lists the methods allowed in cross-origin requests. If
+	// empty, every method registered for the requested path is allowed.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed in cross-origin
+	// requests, in addition to CORS-safelisted headers. If it contains "*",
+	// any header is allowed.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers that clients may read.
+	ExposedHeaders []string
+
+	// AllowCredentials indicates if cross-origin requests may include
+	// credentials, such as cookies.
+	AllowCredentials bool
+
+	// MaxAge is how long the result of a preflight request may be cached. If
+	// zero, no Access-Control-Max-Age header is sent.
+	MaxAge time.Duration
+}
+
+// corsMethods are the methods checked when determining which methods are
+// registered for a path.
+var corsMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// WithCORS is an Option available for NewRouter to handle cross-origin
+// requests. CORS headers are added to responses to allowed origins, and
+// preflight OPTIONS requests are answered automatically for every registered
+// path, without the need to register OPTIONS routes. For example:
+//
+//     jsonrest.NewRouter(jsonrest.WithCORS(jsonrest.CORS{
+//         AllowedOrigins: []string{"https://*.example.com"},
+//         MaxAge:         time.Hour,
+//     }))
+func WithCORS(c CORS) Option {
+	return func(r *Router) {
+		r.cors = &c
+	}
+}
+
+// serveCORS adds CORS headers to the response to a cross-origin request. It
+// returns true if the request was a preflight request, which has been
+// answered.
+func (r *Router) serveCORS(w http.ResponseWriter, req *http.Request) bool {
+	c := r.cors
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	header := w.Header()
+	reqMethod := req.Header.Get("Access-Control-Request-Method")
+	if req.Method != http.MethodOptions || reqMethod == "" {
+		header.Add("Vary", "Origin")
+		if c.allowOrigin(origin) {
+			c.setOrigin(header, origin)
+			if len(c.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+			}
+		}
+		return false
+	}
+
+	methods := r.corsMethods(req.URL.Path)
+	if len(methods) == 0 {
+		// Not a registered path: let the router respond.
+		return false
+	}
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	reqHeaders := parseHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+	if c.allowOrigin(origin) && containsFold(methods, reqMethod) && c.allowHeaders(reqHeaders) {
+		c.setOrigin(header, origin)
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(reqHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+		}
+		if c.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// corsMethods returns the methods allowed in cross-origin requests to the
+// path, which are empty if no route matches the path.
+func (r *Router) corsMethods(path string) []string {
+	var registered []string
+	for _, method := range corsMethods {
+		if h, _, _ := r.router.Lookup(method, path); h != nil {
+			registered = append(registered, method)
+		}
+	}
+	if len(registered) == 0 || len(r.cors.AllowedMethods) == 0 {
+		return registered
+	}
+	return r.cors.AllowedMethods
+}
+
+// setOrigin sets the headers allowing the origin to read the response.
+func (c *CORS) setOrigin(header http.Header, origin string) {
+	if containsFold(c.AllowedOrigins, "*") && !c.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowOrigin reports whether the origin may make cross-origin requests.
+func (c *CORS) allowOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+	for _, pattern := range c.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin reports whether the origin matches the allowed origin, which
+// may contain a "*" wildcard.
+func matchOrigin(allowed, origin string) bool {
+	allowed, origin = strings.ToLower(allowed), strings.ToLower(origin)
+	i := strings.Index(allowed, "*")
+	if i < 0 {
+		return allowed == origin
+	}
+	prefix, suffix := allowed[:i], allowed[i+1:]
+	return len(origin) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+}
+
+// corsSafelistedHeaders are the request headers that are always allowed in
+// cross-origin requests.
+var corsSafelistedHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Content-Language",
+	"Content-Type",
+}
+
+// allowHeaders reports whether all of the headers may be sent in
+// cross-origin requests.
+func (c *CORS) allowHeaders(headers []string) bool {
+	if containsFold(c.AllowedHeaders, "*") {
+		return true
+	}
+	for _, h := range headers {
+		if !containsFold(corsSafelistedHeaders, h) && !containsFold(c.AllowedHeaders, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderList splits a comma-separated list of header names.
+func parseHeaderList(s string) []string {
+	var headers []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, http.CanonicalHeaderKey(h))
+		}
+	}
+	return headers
+}
+
+// containsFold reports whether the list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
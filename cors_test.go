@@ -0,0 +1,141 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestCORS(t *testing.T) {
+	newRouter := func(c jsonrest.CORS) *jsonrest.Router {
+		r := jsonrest.NewRouter(jsonrest.WithCORS(c))
+		endpoint := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			return jsonrest.M{"ok": true}, nil
+		}
+		r.Get("/users/:id", endpoint)
+		r.Delete("/users/:id", endpoint)
+		return r
+	}
+	send := func(r http.Handler, method, origin string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users/1", nil)
+		req.Header.Set("Origin", origin)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("simple request", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{
+			AllowedOrigins: []string{"https://example.com"},
+			ExposedHeaders: []string{"X-Total-Count"},
+		})
+		w := send(r, http.MethodGet, "https://example.com")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "https://example.com")
+		assert.Equal(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count")
+		assert.Equal(t, w.Header().Get("Vary"), "Origin")
+		assert.JSONEqual(t, w.Body.String(), m{"ok": true})
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{AllowedOrigins: []string{"https://example.com"}})
+		w := send(r, http.MethodGet, "https://evil.com")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{AllowedOrigins: []string{"*"}})
+		w := send(r, http.MethodGet, "https://example.com")
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "*")
+	})
+
+	t.Run("any origin with credentials", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+		w := send(r, http.MethodGet, "https://example.com")
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "https://example.com")
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Credentials"), "true")
+	})
+
+	t.Run("wildcard and pattern origins", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{
+			AllowedOrigins:        []string{"https://*.example.com"},
+			AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+		})
+		tests := []struct {
+			origin string
+			want   bool
+		}{
+			{"https://app.example.com", true},
+			{"https://example.com", false},
+			{"https://app.example.com.evil.com", false},
+			{"http://localhost:3000", true},
+			{"http://localhost", false},
+		}
+		for _, tt := range tests {
+			w := send(r, http.MethodGet, tt.origin)
+			assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin") != "", tt.want)
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{
+			AllowedOrigins: []string{"https://example.com"},
+			AllowedHeaders: []string{"Authorization"},
+			MaxAge:         time.Hour,
+		})
+		w := send(r, http.MethodOptions, "https://example.com",
+			"Access-Control-Request-Method", "DELETE",
+			"Access-Control-Request-Headers", "authorization, content-type",
+		)
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "https://example.com")
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Methods"), "GET, DELETE")
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization, Content-Type")
+		assert.Equal(t, w.Header().Get("Access-Control-Max-Age"), "3600")
+		assert.Equal(t, w.Body.Len(), 0)
+	})
+
+	t.Run("preflight with configured methods", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{
+			AllowedOrigins: []string{"https://example.com"},
+			AllowedMethods: []string{"GET"},
+		})
+		w := send(r, http.MethodOptions, "https://example.com", "Access-Control-Request-Method", "GET")
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Methods"), "GET")
+
+		w = send(r, http.MethodOptions, "https://example.com", "Access-Control-Request-Method", "DELETE")
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+	})
+
+	t.Run("preflight with disallowed header", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{AllowedOrigins: []string{"https://example.com"}})
+		w := send(r, http.MethodOptions, "https://example.com",
+			"Access-Control-Request-Method", "GET",
+			"Access-Control-Request-Headers", "X-Secret",
+		)
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+	})
+
+	t.Run("preflight for unknown path", func(t *testing.T) {
+		r := newRouter(jsonrest.CORS{AllowedOrigins: []string{"*"}})
+		req := httptest.NewRequest(http.MethodOptions, "/missing", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, w.Result().StatusCode, 404)
+	})
+}
@@ -1,6 +1,7 @@
 package jsonrest
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,6 +17,25 @@ type HTTPErrorResponse interface {
 	StatusCode() int
 }
 
+// An ErrorRenderer converts an error into the status code and body of the
+// response sent to the client. The body is encoded as JSON, or as problem
+// details if it is a *Problem.
+type ErrorRenderer func(ctx context.Context, r *Request, err error) (status int, body interface{})
+
+// A PanicError is passed to the ErrorRenderer when an endpoint panics.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", err.Value)
+}
+
 // Error creates an error that will be rendered directly to the client.
 func Error(status int, code, message string) *HTTPError {
 	return &HTTPError{
@@ -35,12 +55,42 @@ func NotFound(msg string) *HTTPError {
 	return Error(http.StatusNotFound, "not_found", msg)
 }
 
+// MethodNotAllowed returns an HTTP 405 Method Not Allowed error with a custom
+// error message.
+func MethodNotAllowed(msg string) *HTTPError {
+	return Error(http.StatusMethodNotAllowed, "method_not_allowed", msg)
+}
+
+// NotAcceptable returns an HTTP 406 Not Acceptable error with a custom error
+// message.
+func NotAcceptable(msg string) *HTTPError {
+	return Error(http.StatusNotAcceptable, "not_acceptable", msg)
+}
+
 // Unauthorized returns an HTTP 401 Unauthorized error with a custom error
 // message.
 func Unauthorized(msg string) *HTTPError {
 	return Error(http.StatusUnauthorized, "unauthorized", msg)
 }
 
+// RequestTimeout returns an HTTP 408 Request Timeout error with a custom error
+// message.
+func RequestTimeout(msg string) *HTTPError {
+	return Error(http.StatusRequestTimeout, "request_timeout", msg)
+}
+
+// RequestEntityTooLarge returns an HTTP 413 Request Entity Too Large error
+// with a custom error message.
+func RequestEntityTooLarge(msg string) *HTTPError {
+	return Error(http.StatusRequestEntityTooLarge, "request_entity_too_large", msg)
+}
+
+// UnsupportedMediaType returns an HTTP 415 Unsupported Media Type error with
+// a custom error message.
+func UnsupportedMediaType(msg string) *HTTPError {
+	return Error(http.StatusUnsupportedMediaType, "unsupported_media_type", msg)
+}
+
 // UnprocessableEntity returns an HTTP 422 UnprocessableEntity error with a
 // custom error message.
 func UnprocessableEntity(msg string) *HTTPError {
@@ -59,28 +109,70 @@ type HTTPError struct {
 	Code    string
 	Message string
 	Details []string
+	Fields  []FieldError
 	Status  int
 
 	wrapped error
 }
 
+// A FieldError describes a problem with a single field of the request, in a
+// form that clients can map back to their inputs.
+type FieldError struct {
+	// Field is the path to the field, e.g. "nested.count".
+	Field string `json:"field"`
+
+	// Code is a machine-readable description of the problem.
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the problem.
+	Message string `json:"message"`
+
+	// Meta holds optional additional information, such as the expected type.
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// NewFieldError returns a FieldError for the given field.
+func NewFieldError(field, code, message string) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WithMeta returns a copy of the FieldError with the metadata key set to val.
+func (fe FieldError) WithMeta(key string, val interface{}) FieldError {
+	meta := make(map[string]interface{}, len(fe.Meta)+1)
+	for k, v := range fe.Meta {
+		meta[k] = v
+	}
+	meta[key] = val
+	fe.Meta = meta
+	return fe
+}
+
 // StatusCode implements the HTTPErrorResponse interface.
 func (err *HTTPError) StatusCode() int {
 	return err.Status
 }
 
+// httpErrorEnvelope is the JSON representation of an HTTPError.
+type httpErrorEnvelope struct {
+	Error struct {
+		Code    string       `json:"code"`
+		Message string       `json:"message"`
+		Details []string     `json:"details,omitempty"`
+		Fields  []FieldError `json:"fields,omitempty"`
+	} `json:"error"`
+}
+
 // MarshalJSON implements the json.Marshaler interface.
 func (err *HTTPError) MarshalJSON() ([]byte, error) {
-	var wp struct {
-		Error struct {
-			Code    string   `json:"code"`
-			Message string   `json:"message"`
-			Details []string `json:"details,omitempty"`
-		} `json:"error"`
-	}
+	var wp httpErrorEnvelope
 	wp.Error.Code = err.Code
 	wp.Error.Message = err.Message
 	wp.Error.Details = err.Details
+	wp.Error.Fields = err.Fields
 	return json.Marshal(wp)
 }
 
@@ -95,6 +187,12 @@ func (err *HTTPError) Wrap(inner error) *HTTPError {
 	return err
 }
 
+// WithFields adds structured field errors to the HTTPError.
+func (err *HTTPError) WithFields(fields ...FieldError) *HTTPError {
+	err.Fields = append(err.Fields, fields...)
+	return err
+}
+
 // Unwrap returns the wrapped error, if any.
 func (err *HTTPError) Unwrap() error {
 	return err.wrapped
@@ -1,16 +1,20 @@
 module github.com/deliveroo/jsonrest-go
 
-go 1.12
+go 1.21
 
 require (
 	github.com/deliveroo/assert-go v1.0.3
+	github.com/golangci/golangci-lint v1.18.0
+	github.com/julienschmidt/httprouter v1.2.0
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
 	github.com/gogo/protobuf v1.2.1 // indirect
 	github.com/golang/mock v1.1.1 // indirect
 	github.com/golang/protobuf v1.3.1 // indirect
-	github.com/golangci/golangci-lint v1.18.0
 	github.com/google/go-cmp v0.3.0 // indirect
-	github.com/julienschmidt/httprouter v1.2.0
 	github.com/mattn/go-isatty v0.0.7 // indirect
+	github.com/oliveagle/jsonpath v0.0.0-20180606110733-2e52cf6e6852 // indirect
 	github.com/stretchr/testify v1.3.0 // indirect
-	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
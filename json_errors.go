@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -19,11 +20,32 @@ func jsonErrorDetails(err error) string {
 			typeSuffix = " (expected " + t + ")"
 		}
 		return fmt.Sprintf("offset %d: cannot unmarshal %s to %q%s", err.Offset, err.Value, err.Field, typeSuffix)
+	case *strictJSONError:
+		return fmt.Sprintf("offset %d: %s", err.Offset, strings.TrimPrefix(err.Error(), "json: "))
 	default:
 		return ""
 	}
 }
 
+// jsonFieldError returns a FieldError describing the JSON unmarshal error, if
+// it can be attributed to a single field.
+func jsonFieldError(err error) (FieldError, bool) {
+	if strictErr, ok := err.(*strictJSONError); ok && strictErr.Field != "" {
+		return NewFieldError(strictErr.Field, strictErr.Code, strictErr.Msg), true
+	}
+	typeErr, ok := err.(*json.UnmarshalTypeError)
+	if !ok || typeErr.Field == "" {
+		return FieldError{}, false
+	}
+	msg := "cannot unmarshal " + typeErr.Value
+	fe := NewFieldError(typeErr.Field, "invalid_type", msg).WithMeta("actual", typeErr.Value)
+	if t := jsonType(typeErr.Type); t != "" {
+		fe.Message += " (expected " + t + ")"
+		fe = fe.WithMeta("expected", t)
+	}
+	return fe, true
+}
+
 // jsonType attempts to map the given Go type to its equivalent JSON type. Note
 // that this mapping is incomplete for custom types, since it's impossible to
 // know what a custom UnmarshalJSON implementation may be doing.
@@ -52,6 +74,8 @@ func jsonType(t reflect.Type) (result string) {
 		return "time"
 	case typeTimeDuration:
 		return "duration"
+	case typeUUID:
+		return "uuid"
 	}
 
 	// Structural types:
@@ -68,4 +92,5 @@ func jsonType(t reflect.Type) (result string) {
 var (
 	typeTimeTime     = reflect.TypeOf((*time.Time)(nil)).Elem()
 	typeTimeDuration = reflect.TypeOf((*time.Duration)(nil)).Elem()
+	typeUUID         = reflect.TypeOf((*UUID)(nil)).Elem()
 )
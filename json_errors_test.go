@@ -58,6 +58,34 @@ func TestJSONErrorDetails(t *testing.T) {
 	}
 }
 
+func TestJSONFieldError(t *testing.T) {
+	var dest struct {
+		Nested struct {
+			Count int `json:"count"`
+		} `json:"nested"`
+	}
+
+	err := json.Unmarshal([]byte(`{"nested": {"count": "abc"}}`), &dest)
+	got, ok := jsonFieldError(err)
+	if !ok {
+		t.Fatal("expected a field error")
+	}
+	want := FieldError{
+		Field:   "nested.count",
+		Code:    "invalid_type",
+		Message: "cannot unmarshal string (expected integer)",
+		Meta:    map[string]interface{}{"actual": "string", "expected": "integer"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect field error:\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	err = json.Unmarshal([]byte(`{`), &dest)
+	if _, ok := jsonFieldError(err); ok {
+		t.Error("unexpected field error for syntax error")
+	}
+}
+
 func TestJSONType(t *testing.T) {
 	type Enum int
 	type Person struct{}
@@ -87,3 +115,39 @@ func TestJSONType(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckJSON(t *testing.T) {
+	type Base struct {
+		ID int `json:"id"`
+	}
+	var dest struct {
+		Base
+		Name    string          `json:"name"`
+		Secret  string          `json:"-"`
+		Raw     json.RawMessage `json:"raw"`
+		Any     interface{}     `json:"any"`
+		private int
+	}
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{json: `{"id": 1, "NAME": "a", "raw": {"x": 1}, "any": {"y": [{"z": 1}]}}`},
+		{json: `{"Secret": "a"}`, err: `json: unknown field "Secret"`},
+		{json: `{"private": 1}`, err: `json: unknown field "private"`},
+		{json: `{"any": {"a": 1, "a": 2}}`, err: `json: duplicate key "any.a"`},
+		{json: `{"id": `},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+			err := checkJSON([]byte(tt.json), reflect.TypeOf(&dest), true, true)
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.err {
+				t.Errorf("incorrect error:\ngot:  %s\nwant: %s", got, tt.err)
+			}
+		})
+	}
+}
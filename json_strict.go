@@ -0,0 +1,175 @@
+package jsonrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A strictJSONError reports a JSON body that is well-formed, but rejected by
+// one of the strict decoding options of JSONCodec.
+type strictJSONError struct {
+	Offset int64  // input offset at which the problem was found
+	Field  string // path to the offending field, if any
+	Code   string // e.g. "unknown_field"
+	Msg    string // e.g. "unknown field"
+}
+
+func (e *strictJSONError) Error() string {
+	if e.Field == "" {
+		return "json: " + e.Msg
+	}
+	return fmt.Sprintf("json: %s %q", e.Msg, e.Field)
+}
+
+// checkJSON reports unknown fields and duplicate keys in the first JSON value
+// of data, which is to be decoded into a value of type t. Syntax errors are
+// ignored, so that they are reported by the decoder instead.
+func checkJSON(data []byte, t reflect.Type, unknownFields, duplicateKeys bool) error {
+	c := &jsonChecker{
+		dec:           json.NewDecoder(bytes.NewReader(data)),
+		unknownFields: unknownFields,
+		duplicateKeys: duplicateKeys,
+	}
+	err := c.value(t, "")
+	if _, ok := err.(*strictJSONError); ok {
+		return err
+	}
+	return nil
+}
+
+// jsonChecker walks the tokens of a JSON value alongside the Go type it is
+// decoded into.
+type jsonChecker struct {
+	dec           *json.Decoder
+	unknownFields bool
+	duplicateKeys bool
+}
+
+// value checks the next value, found at path. A nil type accepts any value.
+func (c *jsonChecker) value(t reflect.Type, path string) error {
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	t = checkedType(t)
+	if delim == '{' {
+		return c.object(t, path)
+	}
+	var elem reflect.Type
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		elem = t.Elem()
+	}
+	for i := 0; c.dec.More(); i++ {
+		if err := c.value(elem, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	_, err = c.dec.Token() // ]
+	return err
+}
+
+// object checks the members of an object, found at path, whose opening brace
+// has been read.
+func (c *jsonChecker) object(t reflect.Type, path string) error {
+	var fields map[string]reflect.Type
+	var elem reflect.Type
+	if t != nil && t.Kind() == reflect.Struct {
+		fields = jsonFields(t)
+	} else if t != nil && t.Kind() == reflect.Map {
+		elem = t.Elem()
+	}
+	seen := make(map[string]bool)
+	for c.dec.More() {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		keyPath := joinPath(path, key)
+		if c.duplicateKeys {
+			if seen[key] {
+				return &strictJSONError{c.dec.InputOffset(), keyPath, "duplicate_key", "duplicate key"}
+			}
+			seen[key] = true
+		}
+		valType := elem
+		if fields != nil {
+			f, ok := fields[strings.ToLower(key)]
+			if !ok && c.unknownFields {
+				return &strictJSONError{c.dec.InputOffset(), keyPath, "unknown_field", "unknown field"}
+			}
+			valType = f
+		}
+		if err := c.value(valType, keyPath); err != nil {
+			return err
+		}
+	}
+	_, err := c.dec.Token() // }
+	return err
+}
+
+// checkedType returns the type whose structure is checked for a value of type
+// t, or nil if any value is accepted, e.g. because t unmarshals itself.
+func checkedType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(typeJSONUnmarshaler) {
+		return nil
+	}
+	return t
+}
+
+var typeJSONUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// jsonFields returns the types of the fields that encoding/json decodes into
+// for the struct type t, keyed by lower-cased name, since names are matched
+// case-insensitively.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	var promoted []map[string]reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				promoted = append(promoted, jsonFields(ft))
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Type
+	}
+	// Fields of embedded structs are shadowed by those of the outer struct.
+	for _, embedded := range promoted {
+		for name, ft := range embedded {
+			if _, ok := fields[name]; !ok {
+				fields[name] = ft
+			}
+		}
+	}
+	return fields
+}
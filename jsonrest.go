@@ -1,16 +1,19 @@
 package jsonrest
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"mime/multipart"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -22,6 +25,8 @@ type Request struct {
 	req            *http.Request
 	responseWriter http.ResponseWriter
 	route          string
+	router         *Router
+	onResponse     []func(status int)
 }
 
 // BasicAuth returns the username and password, if the request uses HTTP Basic
@@ -30,25 +35,66 @@ func (r *Request) BasicAuth() (username, password string, ok bool) {
 	return r.req.BasicAuth()
 }
 
-// BindBody unmarshals the request body into the given value.
-func (r *Request) BindBody(val interface{}) error {
+// BindBody unmarshals the request body into the given value, using the codec
+// registered for its Content-Type, then checks it with Validate. JSON bodies
+// are decoded according to the router's JSONCodec, adjusted by opts:
+//
+//     err := req.BindBody(&input, jsonrest.DisallowUnknownFields())
+func (r *Request) BindBody(val interface{}, opts ...DecodeOption) error {
+	if err := r.decodeBody(val, opts...); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+// decodeBody unmarshals the request body into the given value.
+func (r *Request) decodeBody(val interface{}, opts ...DecodeOption) error {
 	defer r.req.Body.Close()
-	if err := json.NewDecoder(r.req.Body).Decode(val); err != nil {
-		msg := "malformed or unexpected json"
+	codec, err := r.router.requestCodec(r.req)
+	if err != nil {
+		return err
+	}
+	if jc, ok := codec.(JSONCodec); ok && len(r.router.decodeOptions)+len(opts) > 0 {
+		for _, opt := range r.router.decodeOptions {
+			opt(&jc)
+		}
+		for _, opt := range opts {
+			opt(&jc)
+		}
+		codec = jc
+	}
+	if err := codec.Decode(r.req.Body, val); err != nil {
+		if httpErr, ok := bodyReadError(err); ok {
+			return httpErr
+		}
+		msg := "malformed or unexpected body"
+		if _, ok := codec.(JSONCodec); ok {
+			msg = "malformed or unexpected json"
+		}
 		if details := jsonErrorDetails(err); details != "" {
 			msg += ": " + details
 		}
-		return BadRequest(msg).Wrap(err)
+		httpErr := BadRequest(msg)
+		if fe, ok := jsonFieldError(err); ok {
+			httpErr = httpErr.WithFields(fe)
+		}
+		return httpErr.Wrap(err)
 	}
 	return nil
 }
 
-// FormFile returns the first file for the provided form key.
-func (r *Request) FormFile(name string, maxMultipartMemory int64) (multipart.File, *multipart.FileHeader, error) {
-	if err := r.req.ParseMultipartForm(maxMultipartMemory); err != nil {
-		return nil, nil, BadRequest("cannot parse multipart form").Wrap(err)
+// bodyReadError returns the HTTPError for a failure to read the request body
+// because it exceeded the size limit or read deadline of the route.
+func bodyReadError(err error) (*HTTPError, bool) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		msg := fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit)
+		return RequestEntityTooLarge(msg).Wrap(err), true
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return RequestTimeout("timed out reading request body").Wrap(err), true
 	}
-	return r.req.FormFile(name)
+	return nil, false
 }
 
 // Get returns the meta value for the key.
@@ -133,12 +179,63 @@ type Router struct {
 	// response; useful for local debugging.
 	DumpErrors bool
 
+	// OnError, if set, is called for every error response sent by the router
+	// or its groups, including panics and unknown URLs, with the original
+	// error and the status sent. It is useful for reporting internal errors,
+	// which are not sent to the client, to an error tracker.
+	OnError ErrorHook
+
 	// notFound is a configurable http.Handler which is called when no matching
 	// route is found. If it is not set, notFoundHandler is used.
 	notFound http.Handler
 
+	// methodNotAllowed is a configurable http.Handler which is called when a
+	// route matches the path but not the method of a request. If it is not
+	// set, methodNotAllowedHandler is used.
+	methodNotAllowed http.Handler
+
+	// problemDetails indicates if HTTPErrors should be rendered as RFC 7807
+	// problem details, with types prefixed by problemTypeBase.
+	problemDetails  bool
+	problemTypeBase string
+
+	// errorRenderer renders errors returned by endpoints. If it is not set,
+	// DefaultErrorRenderer is used.
+	errorRenderer ErrorRenderer
+
+	// writeErrorHook is called when a response cannot be encoded or written.
+	writeErrorHook WriteErrorHook
+
+	// logger receives all messages logged by the router. If it is not set,
+	// slog.Default is used.
+	logger *slog.Logger
+
+	// maxBodyBytes and bodyReadTimeout are the default body limits of routes
+	// registered on the router.
+	maxBodyBytes    int64
+	bodyReadTimeout time.Duration
+
+	// cors configures cross-origin requests, if set.
+	cors *CORS
+
+	// codecs holds the codecs available for request and response bodies,
+	// keyed by media type.
+	codecs map[string]Codec
+
+	// decodeOptions adjust the JSON codec when decoding request bodies.
+	decodeOptions []DecodeOption
+
+	// prefix is prepended to the paths of routes registered on the router.
+	prefix string
+
 	router     *httprouter.Router
 	middleware []Middleware
+	routes     *routeRegistry
+
+	// middlewareVersion is shared by a router and all of its groups, and is
+	// incremented whenever middleware is added to any of them, invalidating
+	// the cached middleware chains.
+	middlewareVersion *uint64
 
 	parent *Router
 }
@@ -153,10 +250,72 @@ func WithNotFoundHandler(h http.Handler) Option {
 	}
 }
 
+// WithMethodNotAllowedHandler is an Option available for NewRouter to
+// configure the handler called when a route matches the path but not the
+// method of a request. The Allow header is set before it is called.
+func WithMethodNotAllowedHandler(h http.Handler) Option {
+	return func(r *Router) {
+		r.methodNotAllowed = h
+	}
+}
+
+// WithMaxBodyBytes is an Option available for NewRouter to limit the size of
+// request bodies to n bytes for every route, unless overridden with
+// WithBodyLimit. Larger bodies are rejected with a 413 error when read.
+func WithMaxBodyBytes(n int64) Option {
+	return func(r *Router) {
+		r.maxBodyBytes = n
+	}
+}
+
+// WithBodyReadTimeout is an Option available for NewRouter to limit the time
+// taken to read request bodies, so that clients sending them slowly are cut
+// off with a 408 error. The deadline is set on the underlying connection with
+// http.ResponseController.
+func WithBodyReadTimeout(d time.Duration) Option {
+	return func(r *Router) {
+		r.bodyReadTimeout = d
+	}
+}
+
+// WithErrorRenderer is an Option available for NewRouter to configure how
+// errors are rendered: those returned by endpoints, as well as panics and
+// requests for unknown URLs.
+func WithErrorRenderer(renderer ErrorRenderer) Option {
+	return func(r *Router) {
+		r.errorRenderer = renderer
+	}
+}
+
+// A WriteErrorHook is called with the error that prevented a response from
+// being sent: either the response could not be encoded, in which case a 500
+// error is sent instead, or it could not be written, typically because the
+// client disconnected.
+type WriteErrorHook func(ctx context.Context, r *Request, err error)
+
+// An ErrorHook is called with an error that is about to be rendered to the
+// client, along with the status of the response. The error is passed as-is,
+// so wrapped causes can be inspected with errors.Unwrap.
+type ErrorHook func(ctx context.Context, r *Request, err error, status int)
+
+// WithWriteErrorHook is an Option available for NewRouter to configure a hook
+// to be notified of responses that could not be sent, e.g. to report them to
+// an error tracker.
+func WithWriteErrorHook(hook WriteErrorHook) Option {
+	return func(r *Router) {
+		r.writeErrorHook = hook
+	}
+}
+
 // NewRouter returns a new initialized Router.
 func NewRouter(options ...Option) *Router {
 	hr := httprouter.New()
-	r := &Router{router: hr}
+	r := &Router{
+		router:            hr,
+		routes:            &routeRegistry{},
+		middlewareVersion: new(uint64),
+		codecs:            map[string]Codec{defaultMediaType: DefaultJSONCodec},
+	}
 
 	for _, option := range options {
 		option(r)
@@ -167,6 +326,11 @@ func NewRouter(options ...Option) *Router {
 	} else {
 		hr.NotFound = r.notFound
 	}
+	if r.methodNotAllowed == nil {
+		hr.MethodNotAllowed = methodNotAllowedHandler(r)
+	} else {
+		hr.MethodNotAllowed = r.methodNotAllowed
+	}
 
 	return r
 }
@@ -174,19 +338,44 @@ func NewRouter(options ...Option) *Router {
 // Use registers a middleware to be used for all routes.
 func (r *Router) Use(ms ...Middleware) {
 	r.middleware = append(r.middleware, ms...)
+	atomic.AddUint64(r.middlewareVersion, 1)
 }
 
 // Group creates a new subrouter, representing a group of routes, from the given
 // Router. This subrouter may have its own middleware, but will also inherit its
-// parent's middleware.
+// parent's middleware and path prefix.
 func (r *Router) Group() *Router {
 	return &Router{
-		parent:     r,
-		router:     r.router,
-		DumpErrors: r.DumpErrors,
+		parent:            r,
+		prefix:            r.prefix,
+		router:            r.router,
+		routes:            r.routes,
+		middlewareVersion: r.middlewareVersion,
+		DumpErrors:        r.DumpErrors,
+		problemDetails:    r.problemDetails,
+		problemTypeBase:   r.problemTypeBase,
+		errorRenderer:     r.errorRenderer,
+		maxBodyBytes:      r.maxBodyBytes,
+		bodyReadTimeout:   r.bodyReadTimeout,
+		cors:              r.cors,
+		codecs:            r.codecs,
+		decodeOptions:     r.decodeOptions,
+		writeErrorHook:    r.writeErrorHook,
+		logger:            r.logger,
 	}
 }
 
+// Prefix creates a new subrouter, like Group, whose routes are registered
+// relative to prefix. For example:
+//
+//     v1 := r.Prefix("/v1")
+//     v1.Get("/users/:id", getUser) // GET /v1/users/:id
+func (r *Router) Prefix(prefix string) *Router {
+	g := r.Group()
+	g.prefix = r.prefix + strings.TrimSuffix(prefix, "/")
+	return g
+}
+
 // RouteMap is a map of a method-path pair to an endpoint. For example:
 //
 //     jsonrest.RouteMap{
@@ -211,94 +400,336 @@ func (r *Router) Routes(m RouteMap) {
 }
 
 // Get is a shortcut for router.Handle(http.MethodGet, path, endpoint).
-func (r *Router) Get(path string, endpoint Endpoint) {
-	r.Handle(http.MethodGet, path, endpoint)
+func (r *Router) Get(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodGet, path, endpoint, opts...)
 }
 
 // Head is a shortcut for router.Handle(http.MethodHead, path, endpoint).
-func (r *Router) Head(path string, endpoint Endpoint) {
-	r.Handle(http.MethodHead, path, endpoint)
+func (r *Router) Head(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodHead, path, endpoint, opts...)
 }
 
 // Post is a shortcut for router.Handle(http.MethodPost, path, endpoint).
-func (r *Router) Post(path string, endpoint Endpoint) {
-	r.Handle(http.MethodPost, path, endpoint)
+func (r *Router) Post(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodPost, path, endpoint, opts...)
+}
+
+// Put is a shortcut for router.Handle(http.MethodPut, path, endpoint).
+func (r *Router) Put(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodPut, path, endpoint, opts...)
+}
+
+// Patch is a shortcut for router.Handle(http.MethodPatch, path, endpoint).
+func (r *Router) Patch(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodPatch, path, endpoint, opts...)
+}
+
+// Delete is a shortcut for router.Handle(http.MethodDelete, path, endpoint).
+func (r *Router) Delete(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodDelete, path, endpoint, opts...)
+}
+
+// Options is a shortcut for router.Handle(http.MethodOptions, path, endpoint).
+func (r *Router) Options(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodOptions, path, endpoint, opts...)
 }
 
-// Handle registers a new endpoint to handle the given path and method.
-func (r *Router) Handle(method, path string, endpoint Endpoint) {
+// Handle registers a new endpoint to handle the given path and method. The
+// route is recorded, along with any options, in the router's route registry.
+func (r *Router) Handle(method, path string, endpoint Endpoint, opts ...RouteOption) {
+	path = r.prefix + path
+	route := &Route{
+		Method:          method,
+		Path:            path,
+		MaxBodyBytes:    r.maxBodyBytes,
+		BodyReadTimeout: r.bodyReadTimeout,
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+	endpoint = route.applyRouteMiddleware(endpoint)
 	endpoint = applyMiddleware(endpoint, r)
+	endpoint = requireAcceptable(route.applyLimits(endpoint), r)
 	handler := endpointToHandler(endpoint, path, r)
 	r.router.Handle(method, path, handler)
+	r.routes.add(route)
+}
+
+// RegisteredRoutes returns every route registered on the router and its
+// groups, in registration order.
+func (r *Router) RegisteredRoutes() []Route {
+	return r.routes.list()
 }
 
 // ServeHTTP implements the http.Handler interface.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.cors != nil && r.serveCORS(w, req) {
+		return
+	}
 	r.router.ServeHTTP(w, req)
 }
 
+// middlewareChain is an endpoint wrapped in middleware, along with the
+// middlewareVersion it was composed at.
+type middlewareChain struct {
+	version  uint64
+	endpoint Endpoint
+}
+
 // applyMiddleware applies the routers's middleware to the provided endpoint.
+// The chain is composed on first use and cached until middleware is added to
+// the router or any router in its family, so that middleware registered after
+// the endpoint still applies.
 func applyMiddleware(e Endpoint, r *Router) Endpoint {
+	var cache atomic.Value // of middlewareChain
 	return func(ctx context.Context, req *Request) (interface{}, error) {
-		e, r := e, r // copy into local var in closure.
+		version := atomic.LoadUint64(r.middlewareVersion)
+		chain, ok := cache.Load().(middlewareChain)
+		if !ok || chain.version != version {
+			chain = middlewareChain{version: version, endpoint: composeMiddleware(e, r)}
+			cache.Store(chain)
+		}
+		return chain.endpoint(ctx, req)
+	}
+}
 
-		// Apply middleware from this router and all parent routers.
-		for {
-			for i := len(r.middleware) - 1; i >= 0; i-- {
-				e = r.middleware[i](e)
-			}
-			if r.parent == nil {
-				break
-			}
-			r = r.parent
+// composeMiddleware wraps the endpoint in the middleware of the router and all
+// of its parents.
+func composeMiddleware(e Endpoint, r *Router) Endpoint {
+	for {
+		for i := len(r.middleware) - 1; i >= 0; i-- {
+			e = r.middleware[i](e)
 		}
-		return e(ctx, req)
+		if r.parent == nil {
+			return e
+		}
+		r = r.parent
 	}
 }
 
 // endpointToHandler converts an endpoint to an httprouter.Handle function.
 func endpointToHandler(e Endpoint, path string, r *Router) func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("panic serving %v: %+v", req.RequestURI, r)
-				debug.PrintStack()
-				sendJSON(w, 500, unknownError)
-			}
-		}()
-		result, err := e(req.Context(), &Request{
+		ctx := req.Context()
+		sw := &statusWriter{ResponseWriter: w}
+		w = sw
+		request := &Request{
 			params:         params,
 			req:            req,
 			responseWriter: w,
 			route:          path,
-		})
+			router:         r,
+		}
+		defer request.responded(sw)
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				stack := debug.Stack()
+				status := http.StatusInternalServerError
+				if sw.status != 0 {
+					status = sw.status
+				}
+				attrs := append(logAttrs(request, status),
+					slog.Any("panic", rcv),
+					slog.String("stack", string(stack)),
+				)
+				r.log().LogAttrs(ctx, slog.LevelError, "panic serving request", attrs...)
+				panicErr := &PanicError{Value: rcv, Stack: stack}
+				if sw.status != 0 {
+					// The response has begun, e.g. with the first values of
+					// a Stream, so the client is told it is incomplete by
+					// aborting the connection instead.
+					if hook := r.errorHook(); hook != nil {
+						hook(ctx, request, panicErr, status)
+					}
+					panic(http.ErrAbortHandler)
+				}
+				r.sendError(ctx, w, request, panicErr)
+			}
+		}()
+		result, err := e(ctx, request)
 		if err != nil {
-			httpErr := translateError(err, r.DumpErrors)
-			sendJSON(w, httpErr.StatusCode(), httpErr)
+			r.sendError(ctx, w, request, err)
 			return
 		}
-		sendJSON(w, 200, result)
+		r.sendResult(ctx, w, request, result)
+	}
+}
+
+// afterResponse registers fn to be called with the status of the response
+// once it has been written, even if the endpoint panicked.
+func (r *Request) afterResponse(fn func(status int)) {
+	r.onResponse = append(r.onResponse, fn)
+}
+
+// responded calls the functions registered with afterResponse.
+func (r *Request) responded(w *statusWriter) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	for _, fn := range r.onResponse {
+		fn(status)
+	}
+}
+
+// requireAcceptable wraps the endpoint so that it is only called if the
+// response can be encoded in a media type acceptable to the client.
+func requireAcceptable(e Endpoint, r *Router) Endpoint {
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		// Clients accepting only NDJSON or events may still be sent a Stream
+		// or Server-Sent Events.
+		if _, _, ok := r.responseCodec(req.req); !ok && !acceptsStream(req.req) {
+			return nil, NotAcceptable("no acceptable media type")
+		}
+		return e(ctx, req)
+	}
+}
+
+// DefaultErrorRenderer is the ErrorRenderer used when none is configured with
+// WithErrorRenderer. Errors that implement HTTPErrorResponse are rendered
+// as-is; any other error is rendered as an unknown error, with details if
+// DumpErrors is set. If the router was created with WithProblemDetails,
+// HTTPErrors are rendered as problem details.
+//
+// Custom renderers may call DefaultErrorRenderer and decorate its result.
+func (r *Router) DefaultErrorRenderer(ctx context.Context, req *Request, err error) (int, interface{}) {
+	errResponse := translateError(err, r.DumpErrors)
+	if httpErr, ok := errResponse.(*HTTPError); ok && r.problemDetails {
+		errResponse = httpErr.problem(r.problemTypeBase, req.URL().Path)
+	}
+	return errResponse.StatusCode(), errResponse
+}
+
+// sendError renders err to the client with the router's ErrorRenderer. Error
+// bodies are always encoded with the JSON codec, whatever the client accepts,
+// so that they keep the shape of the error envelope or problem details.
+func (r *Router) sendError(ctx context.Context, w http.ResponseWriter, req *Request, err error) {
+	status, body := r.renderError(ctx, req, err)
+	contentType, b, err := encodeWith(defaultMediaType, r.codecs[defaultMediaType], req.req, status, body)
+	r.write(ctx, w, req, status, contentType, b, err)
+}
+
+// renderError renders err with the router's ErrorRenderer and reports it to
+// the OnError hook. Internal errors, which are not sent to the client as-is,
+// are logged.
+func (r *Router) renderError(ctx context.Context, req *Request, err error) (int, interface{}) {
+	render := r.errorRenderer
+	if render == nil {
+		render = r.DefaultErrorRenderer
+	}
+	status, body := render(ctx, req, err)
+	if hook := r.errorHook(); hook != nil {
+		hook(ctx, req, err, status)
+	}
+	switch err.(type) {
+	case HTTPErrorResponse, *PanicError:
+		// Panics are logged when they are recovered.
+	default:
+		attrs := append(logAttrs(req, status), slog.Any("error", err))
+		r.log().LogAttrs(ctx, slog.LevelError, "internal error serving request", attrs...)
+	}
+	return status, body
+}
+
+// errorHook returns the OnError hook of the router or, if it has none, of its
+// closest parent.
+func (r *Router) errorHook() ErrorHook {
+	for ; r != nil; r = r.parent {
+		if r.OnError != nil {
+			return r.OnError
+		}
 	}
+	return nil
+}
+
+// send encodes v with the codec negotiated for the request and writes it to
+// the response body. If no codec is acceptable to the client, the default
+// JSON codec is used. The body is omitted for HEAD requests and statuses that
+// do not allow one.
+//
+// The body is encoded before anything is written, so that if encoding fails
+// a 500 unknown error can be sent instead. Encoding and write failures are
+// logged and reported to the router's WriteErrorHook.
+func (r *Router) send(ctx context.Context, w http.ResponseWriter, req *Request, status int, v interface{}) {
+	contentType, body, err := r.encode(req.req, status, v)
+	r.write(ctx, w, req, status, contentType, body, err)
 }
 
-// sendJSON encodes v as JSON and writes it to the response body. Panics
-// if an encoding error occurs.
-func sendJSON(w http.ResponseWriter, status int, v interface{}) {
-	// TODO: Maybe don't panic? This will encounter an error if the caller
-	// closes the response early.
-	w.Header().Set("content-type", "application/json; charset=utf-8")
+// write writes a response with the given status and encoded body, or, if err
+// reports that the body could not be encoded, a 500 unknown error instead.
+func (r *Router) write(ctx context.Context, w http.ResponseWriter, req *Request, status int, contentType string, body []byte, err error) {
+	if err != nil {
+		r.writeFailed(ctx, req, status, fmt.Errorf("jsonrest: cannot encode response: %w", err))
+		status = http.StatusInternalServerError
+		_, v := r.DefaultErrorRenderer(ctx, req, unknownError)
+		contentType, body, _ = encodeWith(defaultMediaType, r.codecs[defaultMediaType], req.req, status, v)
+	}
+	w.Header().Set("content-type", contentType)
 	w.WriteHeader(status)
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(v); err != nil {
-		panic(err)
+	if _, err := w.Write(body); err != nil {
+		r.writeFailed(ctx, req, status, fmt.Errorf("jsonrest: cannot write response: %w", err))
+	}
+}
+
+// encode encodes v with the codec negotiated for the request, returning the
+// content type and the encoded body.
+func (r *Router) encode(req *http.Request, status int, v interface{}) (string, []byte, error) {
+	mediaType, codec, ok := r.responseCodec(req)
+	if !ok {
+		mediaType, codec = defaultMediaType, r.codecs[defaultMediaType]
+	}
+	return encodeWith(mediaType, codec, req, status, v)
+}
+
+// encodeWith encodes v with the given codec, returning the content type and
+// the encoded body, which is empty if the response may not have a body.
+func encodeWith(mediaType string, codec Codec, req *http.Request, status int, v interface{}) (string, []byte, error) {
+	codec = prettyCodec(codec, req)
+	if !bodyAllowed(req, status) {
+		return contentType(mediaType, codec, v), nil, nil
+	}
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, v); err != nil {
+		return "", nil, err
+	}
+	return contentType(mediaType, codec, v), buf.Bytes(), nil
+}
+
+// writeFailed logs err, which prevented a response with the given status from
+// being written, and reports it to the router's WriteErrorHook.
+func (r *Router) writeFailed(ctx context.Context, req *Request, status int, err error) {
+	attrs := append(logAttrs(req, status), slog.Any("error", err))
+	if ctx.Err() != nil {
+		r.log().LogAttrs(ctx, slog.LevelWarn, "client disconnected serving request", attrs...)
+	} else {
+		r.log().LogAttrs(ctx, slog.LevelError, "error serving request", attrs...)
+	}
+	if r.writeErrorHook != nil {
+		r.writeErrorHook(ctx, req, err)
 	}
 }
 
 // notFoundHandler returns a 404 not found response to the caller.
 func notFoundHandler(r *Router) http.Handler {
+	return errorHandler(r, func() error {
+		return Error(404, "not_found", "url not found")
+	})
+}
+
+// methodNotAllowedHandler returns a 405 method not allowed response to the
+// caller. The Allow header has already been set by httprouter.
+func methodNotAllowedHandler(r *Router) http.Handler {
+	return errorHandler(r, func() error {
+		return MethodNotAllowed("method not allowed")
+	})
+}
+
+// errorHandler returns a handler that renders the error returned by newErr to
+// the caller. A new error is made for every request, as renderers and hooks
+// may modify it.
+func errorHandler(r *Router, newErr func() error) http.Handler {
 	endpoint := func(_ context.Context, req *Request) (interface{}, error) {
-		return nil, Error(404, "not_found", "url not found")
+		return nil, newErr()
 	}
 	h := endpointToHandler(endpoint, "", r)
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
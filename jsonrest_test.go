@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -56,6 +58,25 @@ func TestRequestBody(t *testing.T) {
 			},
 		})
 	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users", strings.NewReader(`{"id": "1"}`), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `malformed or unexpected json: offset 10: cannot unmarshal string to "id" (expected integer)`,
+				"fields": []m{
+					{
+						"field":   "id",
+						"code":    "invalid_type",
+						"message": "cannot unmarshal string (expected integer)",
+						"meta":    m{"actual": "string", "expected": "integer"},
+					},
+				},
+			},
+		})
+	})
 }
 
 func TestFormFile(t *testing.T) {
@@ -136,6 +157,61 @@ func TestNotFound(t *testing.T) {
 	})
 }
 
+func TestMethodShortcuts(t *testing.T) {
+	r := jsonrest.NewRouter()
+	endpoint := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"method": r.Method()}, nil
+	}
+	r.Put("/users/:id", endpoint)
+	r.Patch("/users/:id", endpoint)
+	r.Delete("/users/:id", endpoint)
+	r.Options("/users", endpoint)
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		w := do(r, method, "/users/1", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{"method": method})
+	}
+	w := do(r, http.MethodOptions, "/users", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"method": "OPTIONS"})
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	endpoint := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	}
+
+	t.Run("no override", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Get("/users", endpoint)
+		r.Post("/users", endpoint)
+		w := do(r, http.MethodDelete, "/users", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 405)
+		allow := strings.Split(w.Header().Get("Allow"), ", ")
+		sort.Strings(allow)
+		assert.Equal(t, allow, []string{"GET", "OPTIONS", "POST"})
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "method_not_allowed",
+				"message": "method not allowed",
+			},
+		})
+	})
+
+	t.Run("with override", func(t *testing.T) {
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		r := jsonrest.NewRouter(jsonrest.WithMethodNotAllowedHandler(h))
+		r.Get("/users", endpoint)
+		w := do(r, http.MethodDelete, "/users", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 418)
+		assert.Equal(t, w.Header().Get("Allow"), "GET, OPTIONS")
+	})
+}
+
 type testError struct {
 	Message string `json:"message"`
 	status  int
@@ -193,6 +269,113 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestErrorRenderer(t *testing.T) {
+	var r *jsonrest.Router
+	r = jsonrest.NewRouter(jsonrest.WithErrorRenderer(func(ctx context.Context, req *jsonrest.Request, err error) (int, interface{}) {
+		if _, ok := err.(*jsonrest.PanicError); ok {
+			return 503, m{"panic": true}
+		}
+		status, body := r.DefaultErrorRenderer(ctx, req, err)
+		return status, m{"request_id": req.Header("X-Request-Id"), "error": body}
+	}))
+	r.Get("/fail", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.BadRequest("bad")
+	})
+	r.Get("/panic", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+
+	t.Run("endpoint error", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+		req.Header.Set("X-Request-Id", "abc")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"request_id": "abc",
+			"error":      m{"error": m{"code": "bad_request", "message": "bad"}},
+		})
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/missing", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 404)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"request_id": "",
+			"error":      m{"error": m{"code": "not_found", "message": "url not found"}},
+		})
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/panic", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 503)
+		assert.JSONEqual(t, w.Body.String(), m{"panic": true})
+	})
+}
+
+func TestPanic(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/panic", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+
+	w := do(r, http.MethodGet, "/panic", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 500)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "unknown_error",
+			"message": "an unknown error occurred",
+		},
+	})
+}
+
+func TestEncodeError(t *testing.T) {
+	var hookErr error
+	r := jsonrest.NewRouter(jsonrest.WithWriteErrorHook(func(ctx context.Context, req *jsonrest.Request, err error) {
+		hookErr = err
+	}))
+	r.Get("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"value": math.Inf(1)}, nil
+	})
+
+	w := do(r, http.MethodGet, "/", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 500)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "unknown_error",
+			"message": "an unknown error occurred",
+		},
+	})
+	assert.True(t, hookErr != nil)
+	assert.True(t, strings.HasPrefix(hookErr.Error(), "jsonrest: cannot encode response: "))
+}
+
+// failingWriter is an http.ResponseWriter whose writes always fail, as if the
+// client had disconnected.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriteError(t *testing.T) {
+	var hookErr error
+	r := jsonrest.NewRouter(jsonrest.WithWriteErrorHook(func(ctx context.Context, req *jsonrest.Request, err error) {
+		hookErr = err
+	}))
+	r.Get("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"ok": true}, nil
+	})
+
+	w := failingWriter{httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.True(t, hookErr != nil)
+	assert.Equal(t, hookErr.Error(), "jsonrest: cannot write response: broken pipe")
+}
+
 func TestDumpInternalError(t *testing.T) {
 	r := jsonrest.NewRouter()
 	r.DumpErrors = true
@@ -213,6 +396,75 @@ func TestDumpInternalError(t *testing.T) {
 	})
 }
 
+func TestOnError(t *testing.T) {
+	type call struct {
+		route  string
+		err    error
+		status int
+	}
+	var calls []call
+	r := jsonrest.NewRouter()
+	r.OnError = func(ctx context.Context, req *jsonrest.Request, err error, status int) {
+		calls = append(calls, call{req.Route(), err, status})
+	}
+	dbErr := errors.New("connection refused")
+	r.Get("/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("user not found").Wrap(dbErr)
+	})
+	r.Group().Get("/internal", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, dbErr
+	})
+	r.Get("/panic", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+	r.Get("/ok", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		calls = nil
+		do(r, http.MethodGet, "/users/1", nil, "")
+		assert.Equal(t, len(calls), 1)
+		assert.Equal(t, calls[0].route, "/users/:id")
+		assert.Equal(t, calls[0].status, 404)
+		assert.True(t, errors.Is(calls[0].err, dbErr))
+	})
+
+	t.Run("internal error in group", func(t *testing.T) {
+		calls = nil
+		do(r, http.MethodGet, "/internal", nil, "")
+		assert.Equal(t, len(calls), 1)
+		assert.Equal(t, calls[0].err, dbErr)
+		assert.Equal(t, calls[0].status, 500)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		calls = nil
+		do(r, http.MethodGet, "/panic", nil, "")
+		assert.Equal(t, len(calls), 1)
+		panicErr, ok := calls[0].err.(*jsonrest.PanicError)
+		assert.True(t, ok)
+		assert.Equal(t, panicErr.Value, "boom")
+		assert.Equal(t, calls[0].status, 500)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		calls = nil
+		do(r, http.MethodGet, "/missing", nil, "")
+		do(r, http.MethodGet, "/missing", nil, "")
+		assert.Equal(t, len(calls), 2)
+		assert.Equal(t, calls[0].status, 404)
+		// Each request has its own error, which hooks may safely modify.
+		assert.True(t, calls[0].err != calls[1].err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		calls = nil
+		do(r, http.MethodGet, "/ok", nil, "")
+		assert.Equal(t, len(calls), 0)
+	})
+}
+
 func TestMiddleware(t *testing.T) {
 	t.Run("top level middleware", func(t *testing.T) {
 		r := jsonrest.NewRouter()
@@ -256,6 +508,80 @@ func TestMiddleware(t *testing.T) {
 	})
 }
 
+func TestMiddlewareAddedLater(t *testing.T) {
+	var calls []string
+	mw := func(name string) jsonrest.Middleware {
+		return func(next jsonrest.Endpoint) jsonrest.Endpoint {
+			return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+				calls = append(calls, name)
+				return next(ctx, req)
+			}
+		}
+	}
+
+	r := jsonrest.NewRouter()
+	g := r.Group()
+	g.Get("/test", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) { return nil, nil })
+
+	do(r, http.MethodGet, "/test", nil, "application/json")
+	assert.Equal(t, len(calls), 0)
+
+	r.Use(mw("parent"))
+	g.Use(mw("group"))
+	do(r, http.MethodGet, "/test", nil, "application/json")
+	assert.Equal(t, calls, []string{"parent", "group"})
+
+	calls = nil
+	do(r, http.MethodGet, "/test", nil, "application/json")
+	assert.Equal(t, calls, []string{"parent", "group"})
+}
+
+func TestPrefix(t *testing.T) {
+	r := jsonrest.NewRouter()
+	endpoint := func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"route": req.Route(), "id": req.Param("id")}, nil
+	}
+	v1 := r.Prefix("/v1/")
+	v1.Get("/users/:id", endpoint)
+	v1.Prefix("/admin").Get("/users/:id", endpoint)
+
+	w := do(r, http.MethodGet, "/v1/users/1", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"route": "/v1/users/:id", "id": "1"})
+
+	w = do(r, http.MethodGet, "/v1/admin/users/2", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"route": "/v1/admin/users/:id", "id": "2"})
+
+	w = do(r, http.MethodGet, "/users/1", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 404)
+
+	routes := r.RegisteredRoutes()
+	assert.Equal(t, len(routes), 2)
+	assert.Equal(t, routes[0].Path, "/v1/users/:id")
+	assert.Equal(t, routes[1].Path, "/v1/admin/users/:id")
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	nop := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			return next(ctx, req)
+		}
+	}
+	r := jsonrest.NewRouter()
+	r.Use(nop, nop, nop)
+	g := r.Group()
+	g.Use(nop, nop, nop)
+	g.Get("/bench", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) { return nil, nil })
+
+	req := httptest.NewRequest(http.MethodGet, "/bench", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
 type m map[string]interface{}
 
 func do(h http.Handler, method, path string, body io.Reader, contentType string) *httptest.ResponseRecorder {
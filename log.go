@@ -0,0 +1,70 @@
+package jsonrest
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader is the header from which the request ID logged with each
+// request is read.
+const requestIDHeader = "X-Request-Id"
+
+// WithLogger is an Option available for NewRouter to configure the logger
+// used for all messages emitted by the router: panics, internal errors and
+// responses that could not be sent. If it is not set, slog.Default is used.
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Router) {
+		r.logger = logger
+	}
+}
+
+// log returns the router's logger.
+func (r *Router) log() *slog.Logger {
+	if r.logger == nil {
+		return slog.Default()
+	}
+	return r.logger
+}
+
+// logAttrs returns the attributes describing the request and the status of
+// its response.
+func logAttrs(req *Request, status int) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method()),
+		slog.String("route", req.Route()),
+		slog.String("path", req.URL().Path),
+		slog.Int("status", status),
+	}
+	if id := req.Header(requestIDHeader); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	return attrs
+}
+
+// AccessLog is a Middleware that logs every request handled by an endpoint,
+// along with the status of its response and the time taken, using the
+// router's logger. Requests that result in server errors are logged at error
+// level; all others at info level.
+//
+//     r := jsonrest.NewRouter(jsonrest.WithLogger(logger))
+//     r.Use(jsonrest.AccessLog)
+//
+// Requests are logged once their response has been written, so the status is
+// the one sent to the client, e.g. as chosen by an ErrorRenderer or after a
+// panic, and the time taken includes writing the response.
+func AccessLog(next Endpoint) Endpoint {
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		start := time.Now()
+		req.afterResponse(func(status int) {
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := append(logAttrs(req, status), slog.Duration("duration", time.Since(start)))
+			req.router.log().LogAttrs(ctx, level, "request", attrs...)
+		})
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,174 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+// logRecords decodes the JSON log records written to buf.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		assert.Must(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	r := jsonrest.NewRouter(jsonrest.WithLogger(logger))
+	r.Get("/panic/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+	r.Get("/internal", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("database unavailable")
+	})
+	r.Get("/bad", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.BadRequest("bad")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/panic/1", nil)
+		req.Header.Set("X-Request-Id", "abc")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, w.Result().StatusCode, 500)
+
+		records := logRecords(t, &buf)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0]["level"], "ERROR")
+		assert.Equal(t, records[0]["msg"], "panic serving request")
+		assert.Equal(t, records[0]["panic"], "boom")
+		assert.Equal(t, records[0]["method"], "GET")
+		assert.Equal(t, records[0]["route"], "/panic/:id")
+		assert.Equal(t, records[0]["path"], "/panic/1")
+		assert.Equal(t, records[0]["status"], float64(500))
+		assert.Equal(t, records[0]["request_id"], "abc")
+		assert.True(t, strings.Contains(records[0]["stack"].(string), "goroutine"))
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		buf.Reset()
+		w := do(r, http.MethodGet, "/internal", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 500)
+
+		records := logRecords(t, &buf)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0]["msg"], "internal error serving request")
+		assert.Equal(t, records[0]["error"], "database unavailable")
+		assert.Equal(t, records[0]["route"], "/internal")
+		assert.Equal(t, records[0]["request_id"], nil)
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		buf.Reset()
+		w := do(r, http.MethodGet, "/bad", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.Equal(t, buf.Len(), 0)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		buf.Reset()
+		w := failingWriter{httptest.NewRecorder()}
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bad", nil))
+
+		records := logRecords(t, &buf)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0]["msg"], "error serving request")
+		assert.Equal(t, records[0]["error"], "jsonrest: cannot write response: broken pipe")
+		assert.Equal(t, records[0]["status"], float64(400))
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	r := jsonrest.NewRouter(jsonrest.WithLogger(logger))
+	r.Use(jsonrest.AccessLog)
+	r.Post("/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Created("/users/1", jsonrest.M{"id": 1}), nil
+	})
+	r.Get("/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("user not found")
+	})
+	r.Get("/panic", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		buf.Reset()
+		do(r, http.MethodPost, "/users", nil, "")
+
+		records := logRecords(t, &buf)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0]["level"], "INFO")
+		assert.Equal(t, records[0]["msg"], "request")
+		assert.Equal(t, records[0]["method"], "POST")
+		assert.Equal(t, records[0]["route"], "/users")
+		assert.Equal(t, records[0]["status"], float64(201))
+		assert.True(t, records[0]["duration"] != nil)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		buf.Reset()
+		do(r, http.MethodGet, "/users/2", nil, "")
+
+		records := logRecords(t, &buf)
+		assert.Equal(t, len(records), 1)
+		assert.Equal(t, records[0]["route"], "/users/:id")
+		assert.Equal(t, records[0]["path"], "/users/2")
+		assert.Equal(t, records[0]["status"], float64(404))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		buf.Reset()
+		do(r, http.MethodGet, "/panic", nil, "")
+
+		records := logRecords(t, &buf)
+		assert.Equal(t, len(records), 2)
+		assert.Equal(t, records[0]["msg"], "panic serving request")
+		assert.Equal(t, records[1]["msg"], "request")
+		assert.Equal(t, records[1]["level"], "ERROR")
+		assert.Equal(t, records[1]["status"], float64(500))
+	})
+}
+
+func TestAccessLogErrorRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	r := jsonrest.NewRouter(
+		jsonrest.WithLogger(logger),
+		jsonrest.WithErrorRenderer(func(ctx context.Context, r *jsonrest.Request, err error) (int, interface{}) {
+			return http.StatusTeapot, jsonrest.M{"message": err.Error()}
+		}),
+	)
+	r.Use(jsonrest.AccessLog)
+	r.Get("/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("user not found")
+	})
+
+	w := do(r, http.MethodGet, "/users/2", nil, "")
+	assert.Equal(t, w.Code, http.StatusTeapot)
+
+	records := logRecords(t, &buf)
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0]["status"], float64(http.StatusTeapot))
+}
@@ -0,0 +1,76 @@
+package jsonrest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// mountParam is the name of the catch-all parameter holding the path of a
+// request to a mounted handler, relative to its prefix.
+const mountParam = "mount"
+
+// mountMethods are the methods routed to mounted handlers.
+var mountMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// Mount attaches a foreign http.Handler, such as a file server or
+// net/http/pprof, to handle every request whose path starts with prefix,
+// relative to the router's own prefix. As with http.StripPrefix, the prefix is
+// removed from the request path before h is called. For example:
+//
+//     r.Mount("/static", http.FileServer(http.Dir("public")))
+//
+// Requests pass through the router's middleware, which may reject them
+// before they reach h, but responses are written by h directly. Mounted
+// handlers are not recorded in the route registry.
+//
+// The prefix cannot be shared with routes registered on the router, e.g.
+// "/static" with "/static/index.html", and Mount panics if it is. A handler
+// mounted at the root, with the prefix "/" or "", is the exception: it serves
+// every request that matches no route, in place of the NotFound handler.
+func (r *Router) Mount(prefix string, h http.Handler) {
+	root := r.prefix + strings.TrimSuffix(prefix, "/")
+	path := root + "/*" + mountParam
+	endpoint := func(ctx context.Context, req *Request) (interface{}, error) {
+		raw := new(http.Request)
+		*raw = *req.req.WithContext(ctx)
+		raw.URL = new(url.URL)
+		*raw.URL = *req.req.URL
+		raw.URL.Path = req.Param(mountParam)
+		raw.URL.RawPath = ""
+
+		h.ServeHTTP(req.responseWriter, raw)
+		return writtenResponse{}, nil
+	}
+	handler := endpointToHandler(applyMiddleware(endpoint, r), path, r)
+	if root == "" {
+		r.router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handler(w, req, httprouter.Params{{Key: mountParam, Value: req.URL.Path}})
+		})
+		return
+	}
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			panic(fmt.Sprintf("jsonrest: cannot mount handler at %q: %v", root, rcv))
+		}
+	}()
+	for _, method := range mountMethods {
+		r.router.Handle(method, path, handler)
+	}
+}
+
+// writtenResponse is the result of an endpoint, such as a mounted handler,
+// which has already written the response itself.
+type writtenResponse struct{}
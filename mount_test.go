@@ -0,0 +1,132 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestMount(t *testing.T) {
+	var buf bytes.Buffer
+	r := jsonrest.NewRouter(jsonrest.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	r.Use(jsonrest.AccessLog)
+	api := r.Prefix("/api")
+	api.Use(func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			if req.Header("Authorization") == "" {
+				return nil, jsonrest.Unauthorized("missing credentials")
+			}
+			return next(ctx, req)
+		}
+	})
+	api.Mount("/files/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = io.WriteString(w, req.Method+" "+req.URL.Path)
+	}))
+
+	send := func(method, path string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept", "text/plain")
+		if authorized {
+			req.Header.Set("Authorization", "secret")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("strips prefix", func(t *testing.T) {
+		buf.Reset()
+		w := send(http.MethodPut, "/api/files/a/b.txt", true)
+		assert.Equal(t, w.Result().StatusCode, 418)
+		assert.Equal(t, w.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(t, w.Body.String(), "PUT /a/b.txt")
+		assert.True(t, bytes.Contains(buf.Bytes(), []byte(`"status":418`)))
+	})
+
+	t.Run("root", func(t *testing.T) {
+		w := send(http.MethodGet, "/api/files/", true)
+		assert.Equal(t, w.Body.String(), "GET /")
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		w := send(http.MethodGet, "/api/files/a", false)
+		assert.Equal(t, w.Result().StatusCode, 401)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "unauthorized",
+				"message": "missing credentials",
+			},
+		})
+	})
+
+	t.Run("not recorded", func(t *testing.T) {
+		assert.Equal(t, len(r.RegisteredRoutes()), 0)
+	})
+}
+
+func TestMountRoot(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/users", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"users": []string{}}, nil
+	})
+	r.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, req.Method+" "+req.URL.Path)
+	}))
+
+	w := do(r, http.MethodGet, "/index.html", nil, "")
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), "GET /index.html")
+
+	w = do(r, http.MethodGet, "/users", nil, "")
+	assert.Equal(t, w.Code, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"users": []string{}})
+}
+
+func TestMountConflict(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/static/index.html", nopEndpoint)
+	defer func() {
+		rcv, _ := recover().(string)
+		assert.True(t, strings.HasPrefix(rcv, `jsonrest: cannot mount handler at "/static": `))
+	}()
+	r.Mount("/static", http.NotFoundHandler())
+}
+
+func TestMountHijack(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Mount("/ws", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "cannot hijack", http.StatusInternalServerError)
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = rw.Flush()
+	}))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/ws/")
+	assert.Must(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.Must(t, err)
+	assert.Equal(t, resp.StatusCode, 200)
+	assert.Equal(t, string(body), "hijacked")
+}
@@ -0,0 +1,292 @@
+package jsonrest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+)
+
+// defaultMaxMemory is the number of bytes of file parts that MultipartForm
+// holds in memory by default, as in net/http.
+const defaultMaxMemory = 32 << 20
+
+// A MultipartOption configures how a multipart request body is read.
+type MultipartOption func(*multipartConfig)
+
+type multipartConfig struct {
+	maxMemory    int64
+	maxFileBytes int64
+	contentTypes []string
+}
+
+// MaxMemory sets the number of bytes of file parts that MultipartForm holds in
+// memory; the remainder is stored in temporary files, which are removed once
+// the request is complete. The default is 32 MB.
+func MaxMemory(n int64) MultipartOption {
+	return func(c *multipartConfig) { c.maxMemory = n }
+}
+
+// MaxFileBytes limits the size of each file in a multipart body. Larger files
+// are rejected with a 413 Request Entity Too Large HTTPError.
+func MaxFileBytes(n int64) MultipartOption {
+	return func(c *multipartConfig) { c.maxFileBytes = n }
+}
+
+// AllowContentTypes restricts the Content-Type of each file in a multipart
+// body to one of the given media types, such as "application/pdf"; a type
+// ending in /* matches any subtype, as in "image/*". Files of other types are
+// rejected with a 415 Unsupported Media Type HTTPError.
+func AllowContentTypes(types ...string) MultipartOption {
+	return func(c *multipartConfig) { c.contentTypes = types }
+}
+
+func newMultipartConfig(opts []MultipartOption) multipartConfig {
+	c := multipartConfig{maxMemory: defaultMaxMemory}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// MultipartForm parses the multipart form in the request body and returns it,
+// holding every file and regular value of each field. The body is only parsed
+// by the first call; later calls return the same form. The files are checked
+// against the options on every call:
+//
+//     form, err := req.MultipartForm(
+//         jsonrest.MaxFileBytes(10<<20),
+//         jsonrest.AllowContentTypes("image/*"),
+//     )
+//
+// A body that is not a valid multipart form is reported as a 400 Bad Request
+// HTTPError.
+func (r *Request) MultipartForm(opts ...MultipartOption) (*multipart.Form, error) {
+	c := newMultipartConfig(opts)
+	if err := r.req.ParseMultipartForm(c.maxMemory); err != nil {
+		if httpErr, ok := bodyReadError(err); ok {
+			return nil, httpErr
+		}
+		return nil, BadRequest("cannot parse multipart form").Wrap(err)
+	}
+	form := r.req.MultipartForm
+	for _, files := range form.File {
+		for _, fh := range files {
+			if err := c.checkFile(fh.Filename, fh.Header, fh.Size); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return form, nil
+}
+
+// FormFile returns the first file for the provided form key.
+func (r *Request) FormFile(name string, maxMultipartMemory int64) (multipart.File, *multipart.FileHeader, error) {
+	form, err := r.MultipartForm(MaxMemory(maxMultipartMemory))
+	if err != nil {
+		return nil, nil, err
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, nil, http.ErrMissingFile
+	}
+	f, err := files[0].Open()
+	return f, files[0], err
+}
+
+// BindForm populates the struct pointed to by v from the multipart form in the
+// request body, then validates it. Regular values are bound according to
+// their `form:"name"` tags, as BindQuery does; fields of type
+// *multipart.FileHeader or []*multipart.FileHeader receive the files of the
+// field instead:
+//
+//     var upload struct {
+//         Title  string                  `form:"title" validate:"required"`
+//         Tags   []string                `form:"tag"`
+//         Cover  *multipart.FileHeader   `form:"cover" validate:"required"`
+//         Photos []*multipart.FileHeader `form:"photo"`
+//     }
+//
+// File fields must be declared at the top level of the struct. The form is
+// parsed, and its files checked, as by MultipartForm.
+func (r *Request) BindForm(v interface{}, opts ...MultipartOption) error {
+	form, err := r.MultipartForm(opts...)
+	if err != nil {
+		return err
+	}
+	lookup := func(name string) ([]string, bool) {
+		values, ok := form.Value[name]
+		return values, ok
+	}
+	if err := bindTagged(v, "form", "form", lookup); err != nil {
+		return err
+	}
+	bindFiles(reflect.ValueOf(v).Elem(), form.File)
+	return Validate(v)
+}
+
+var (
+	typeFileHeader      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	typeFileHeaderSlice = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// isFileField reports whether a field of type t is bound by bindFiles.
+func isFileField(t reflect.Type) bool {
+	return t == typeFileHeader || t == typeFileHeaderSlice
+}
+
+// bindFiles sets the file fields of the struct v from files, according to
+// their `form` tags.
+func bindFiles(v reflect.Value, files map[string][]*multipart.FileHeader) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || !isFileField(field.Type) {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("form"), ",")[0]
+		if name == "" || name == "-" || len(files[name]) == 0 {
+			continue
+		}
+		if field.Type == typeFileHeader {
+			v.Field(i).Set(reflect.ValueOf(files[name][0]))
+		} else {
+			v.Field(i).Set(reflect.ValueOf(files[name]))
+		}
+	}
+}
+
+// MultipartReader returns a reader over the parts of the multipart request
+// body, for streaming large files without buffering them. The options are
+// enforced as the parts are read: NextPart rejects files whose Content-Type
+// is not allowed, and reading a file beyond MaxFileBytes fails with a 413
+// Request Entity Too Large HTTPError. MaxMemory does not apply.
+//
+// A body that is not multipart is reported as a 400 Bad Request HTTPError.
+func (r *Request) MultipartReader(opts ...MultipartOption) (*MultipartReader, error) {
+	mr, err := r.req.MultipartReader()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, BadRequest("cannot parse multipart form").Wrap(err)
+		}
+		return nil, err
+	}
+	return &MultipartReader{r: mr, config: newMultipartConfig(opts)}, nil
+}
+
+// A MultipartReader iterates over the parts of a multipart request body.
+type MultipartReader struct {
+	r      *multipart.Reader
+	config multipartConfig
+}
+
+// NextPart returns the next part of the body, or io.EOF once there are no
+// more parts.
+func (mr *MultipartReader) NextPart() (*Part, error) {
+	p, err := mr.r.NextPart()
+	if err != nil {
+		return nil, multipartReadError(err)
+	}
+	part := &Part{Part: p, r: p}
+	if p.FileName() == "" {
+		return part, nil
+	}
+	if err := mr.config.checkFile(p.FileName(), p.Header, 0); err != nil {
+		return nil, err
+	}
+	if mr.config.maxFileBytes > 0 {
+		limit := mr.config.maxFileBytes
+		part.r = &fileLimitReader{r: p, n: limit, filename: p.FileName(), limit: limit}
+	}
+	return part, nil
+}
+
+// A Part is a single part of a multipart body, read from a MultipartReader.
+type Part struct {
+	*multipart.Part
+	r io.Reader
+}
+
+// Read reads the body of the part, after its headers.
+func (p *Part) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	return n, multipartReadError(err)
+}
+
+// multipartReadError translates a failure to read a multipart body into an
+// HTTPError, leaving io.EOF and errors that are HTTPErrors already untouched.
+func multipartReadError(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	if _, ok := err.(*HTTPError); ok {
+		return err
+	}
+	if httpErr, ok := bodyReadError(err); ok {
+		return httpErr
+	}
+	return BadRequest("cannot parse multipart form").Wrap(err)
+}
+
+// fileLimitReader reads at most n more bytes of a file, failing with a 413
+// Request Entity Too Large HTTPError if the file is larger than limit.
+type fileLimitReader struct {
+	r        io.Reader
+	n        int64
+	filename string
+	limit    int64
+}
+
+func (l *fileLimitReader) Read(p []byte) (int, error) {
+	// Read one byte more than allowed, to tell whether the file is too large.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+	n, l.n = int(l.n), 0
+	return n, fileTooLarge(l.filename, l.limit)
+}
+
+// checkFile checks the headers of a file, and its size if known, against the
+// limits of c.
+func (c *multipartConfig) checkFile(filename string, header textproto.MIMEHeader, size int64) error {
+	if c.maxFileBytes > 0 && size > c.maxFileBytes {
+		return fileTooLarge(filename, c.maxFileBytes)
+	}
+	if len(c.contentTypes) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	for _, allowed := range c.contentTypes {
+		if matchMediaType(allowed, mediaType) {
+			return nil
+		}
+	}
+	return UnsupportedMediaType(fmt.Sprintf(
+		"file %q has unsupported content-type %q (expected one of %s)",
+		filename, mediaType, strings.Join(c.contentTypes, ", "),
+	))
+}
+
+func fileTooLarge(filename string, limit int64) *HTTPError {
+	return RequestEntityTooLarge(fmt.Sprintf("file %q exceeds %d bytes", filename, limit))
+}
+
+// matchMediaType reports whether mediaType matches the pattern, which may end
+// in /* to match any subtype.
+func matchMediaType(pattern, mediaType string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == mediaType
+}
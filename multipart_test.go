@@ -0,0 +1,228 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+// multipartBody builds a multipart body from name-value pairs. Names of the
+// form "field:filename:content-type" are written as files.
+func multipartBody(t *testing.T, fields ...string) (*bytes.Buffer, string) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	for i := 0; i < len(fields); i += 2 {
+		var err error
+		var w io.Writer
+		if parts := strings.Split(fields[i], ":"); len(parts) == 3 {
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, parts[0], parts[1]))
+			h.Set("Content-Type", parts[2])
+			w, err = mw.CreatePart(h)
+		} else {
+			w, err = mw.CreateFormField(fields[i])
+		}
+		assert.Must(t, err)
+		_, err = w.Write([]byte(fields[i+1]))
+		assert.Must(t, err)
+	}
+	assert.Must(t, mw.Close())
+	return buf, mw.FormDataContentType()
+}
+
+func TestMultipartForm(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Post("/upload", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		form, err := req.MultipartForm(jsonrest.MaxFileBytes(5), jsonrest.AllowContentTypes("image/*", "text/plain"))
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, fh := range form.File["photo"] {
+			files = append(files, fh.Filename)
+		}
+		// The form is only parsed once.
+		again, err := req.MultipartForm()
+		if err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"title": form.Value["title"], "files": files, "same": form == again}, nil
+	})
+
+	t.Run("values and files", func(t *testing.T) {
+		body, contentType := multipartBody(t,
+			"title", "holiday",
+			"photo:a.png:image/png", "aaa",
+			"photo:b.txt:text/plain; charset=utf-8", "bbb",
+		)
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"title": []string{"holiday"},
+			"files": []string{"a.png", "b.txt"},
+			"same":  true,
+		})
+	})
+
+	t.Run("file too large", func(t *testing.T) {
+		body, contentType := multipartBody(t, "photo:a.png:image/png", "aaaaaa")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 413)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "request_entity_too_large",
+				"message": `file "a.png" exceeds 5 bytes`,
+			},
+		})
+	})
+
+	t.Run("content type not allowed", func(t *testing.T) {
+		body, contentType := multipartBody(t, "photo:a.pdf:application/pdf", "aaa")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 415)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "unsupported_media_type",
+				"message": `file "a.pdf" has unsupported content-type "application/pdf" (expected one of image/*, text/plain)`,
+			},
+		})
+	})
+
+	t.Run("not multipart", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/upload", strings.NewReader("{}"), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 400)
+	})
+}
+
+func TestBindForm(t *testing.T) {
+	type upload struct {
+		Title  string                  `form:"title" validate:"required"`
+		Tags   []string                `form:"tag"`
+		Public bool                    `form:"public" default:"true"`
+		Cover  *multipart.FileHeader   `form:"cover" validate:"required"`
+		Photos []*multipart.FileHeader `form:"photo"`
+	}
+	r := jsonrest.NewRouter()
+	r.Post("/upload", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var in upload
+		if err := req.BindForm(&in); err != nil {
+			return nil, err
+		}
+		photos := []string{}
+		for _, fh := range in.Photos {
+			photos = append(photos, fh.Filename)
+		}
+		return jsonrest.M{
+			"title":  in.Title,
+			"tags":   in.Tags,
+			"public": in.Public,
+			"cover":  in.Cover.Filename,
+			"photos": photos,
+		}, nil
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		body, contentType := multipartBody(t,
+			"title", "holiday",
+			"tag", "beach,sun",
+			"tag", "sea",
+			"cover:cover.png:image/png", "c",
+			"photo:a.png:image/png", "a",
+			"photo:b.png:image/png", "b",
+		)
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"title":  "holiday",
+			"tags":   []string{"beach", "sun", "sea"},
+			"public": true,
+			"cover":  "cover.png",
+			"photos": []string{"a.png", "b.png"},
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		body, contentType := multipartBody(t, "title", "holiday", "public", "maybe")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `invalid form parameter: cannot unmarshal "maybe" to "public" (expected boolean)`,
+			},
+		})
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		body, contentType := multipartBody(t, "title", "holiday")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 422)
+	})
+}
+
+func TestMultipartReader(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Post("/upload", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		mr, err := req.MultipartReader(jsonrest.MaxFileBytes(5), jsonrest.AllowContentTypes("text/plain"))
+		if err != nil {
+			return nil, err
+		}
+		sizes := jsonrest.M{}
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			n, err := io.Copy(io.Discard, part)
+			if err != nil {
+				return nil, err
+			}
+			sizes[part.FormName()] = n
+		}
+		return sizes, nil
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		body, contentType := multipartBody(t, "title", "a long title", "file:a.txt:text/plain", "12345")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{"title": 12, "file": 5})
+	})
+
+	t.Run("file too large", func(t *testing.T) {
+		body, contentType := multipartBody(t, "file:a.txt:text/plain", "123456")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 413)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "request_entity_too_large",
+				"message": `file "a.txt" exceeds 5 bytes`,
+			},
+		})
+	})
+
+	t.Run("content type not allowed", func(t *testing.T) {
+		body, contentType := multipartBody(t, "file:a.png:image/png", "1")
+		w := do(r, http.MethodPost, "/upload", body, contentType)
+		assert.Equal(t, w.Result().StatusCode, 415)
+	})
+
+	t.Run("not multipart", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/upload", strings.NewReader("{}"), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 400)
+	})
+}
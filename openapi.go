@@ -0,0 +1,492 @@
+package jsonrest
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// openAPIVersion is the version of the OpenAPI specification that generated
+// documents conform to.
+const openAPIVersion = "3.0.3"
+
+// OpenAPIInfo provides metadata about the API for a generated OpenAPI
+// document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// An OpenAPIDocument is an OpenAPI 3 document generated from the routes
+// registered on a Router. It is rendered by encoding/json, or as YAML by
+// calling YAML.
+type OpenAPIDocument struct {
+	doc openAPIDoc
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d *OpenAPIDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.doc)
+}
+
+// YAML returns the document encoded as YAML.
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	b, err := json.Marshal(d.doc)
+	if err != nil {
+		return nil, err
+	}
+	// Round-trip through an ordered map so that the YAML document keeps the
+	// same key order as the JSON one.
+	var v yaml.MapSlice
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// OpenAPI returns an OpenAPI 3 document describing every documented route
+// registered on the router and its groups. Request and response schemas are
+// derived from the types given by WithRequest and WithResponse; every
+// operation also describes the error envelope rendered for an HTTPError.
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	g := &openAPIGenerator{
+		schemas:          make(map[string]*openAPISchema),
+		names:            make(map[reflect.Type]string),
+		claimed:          make(map[string]reflect.Type),
+		errorContentType: "application/json",
+	}
+	if r.problemDetails {
+		t := reflect.TypeOf(problemMembers{})
+		g.claimed[openAPIErrorSchema] = t
+		schema := g.objectSchema(t, nil)
+		schema.AdditionalProperties = &openAPISchema{} // extension members
+		g.schemas[openAPIErrorSchema] = schema
+		g.errorContentType = problemContentType
+	} else {
+		t := reflect.TypeOf(httpErrorEnvelope{})
+		g.claimed[openAPIErrorSchema] = t
+		g.schemas[openAPIErrorSchema] = g.objectSchema(t, nil)
+	}
+
+	doc := openAPIDoc{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]map[string]*openAPIOperation),
+	}
+	for _, route := range r.RegisteredRoutes() {
+		if route.Undocumented {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(map[string]*openAPIOperation)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = g.operation(route, params)
+	}
+	doc.Components.Schemas = g.schemas
+	return &OpenAPIDocument{doc: doc}
+}
+
+// ServeOpenAPI registers a GET endpoint at path that serves the router's
+// OpenAPI document as JSON. The document is generated on each request, so it
+// includes routes registered after ServeOpenAPI is called.
+func (r *Router) ServeOpenAPI(path string, info OpenAPIInfo) {
+	r.Get(path, func(ctx context.Context, req *Request) (interface{}, error) {
+		return r.OpenAPI(info), nil
+	}, Undocumented())
+}
+
+// openAPIErrorSchema is the name of the component schema describing the
+// HTTPError envelope.
+const openAPIErrorSchema = "Error"
+
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// openAPIPath converts an httprouter pattern such as /users/:id/*path into an
+// OpenAPI path template such as /users/{id}/{path}, returning the names of
+// the parameters it contains.
+func openAPIPath(pattern string) (string, []string) {
+	var params []string
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// openAPIGenerator accumulates the component schemas referenced by the
+// operations of a document.
+type openAPIGenerator struct {
+	schemas          map[string]*openAPISchema
+	names            map[reflect.Type]string // component names of named types
+	claimed          map[string]reflect.Type // types holding component names
+	errorContentType string
+}
+
+// operation describes a single route.
+func (g *openAPIGenerator) operation(route Route, pathParams []string) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: route.Name,
+		Tags:        route.Tags,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Deprecated:  route.Deprecated,
+		Responses:   make(map[string]*openAPIResponse),
+	}
+
+	// Parameters: every path parameter is required, and is described by the
+	// matching `param` field of the request type when there is one.
+	fields := taggedFields(route.Request)
+	for _, name := range pathParams {
+		schema := &openAPISchema{Type: "string"}
+		if f, ok := fields["param"][name]; ok {
+			schema = g.schemaOf(f.Type)
+		}
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name: name, In: "path", Required: true, Schema: schema,
+		})
+	}
+	for _, in := range []string{"query", "header"} {
+		for _, name := range sortedKeys(fields[in]) {
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name: name, In: in, Schema: g.schemaOf(fields[in][name].Type),
+			})
+		}
+	}
+
+	if route.Request != nil && methodHasBody(route.Method) {
+		if schema := g.requestBodySchema(route.Request); schema != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  jsonContent(schema),
+			}
+		}
+	}
+
+	success := &openAPIResponse{Description: http.StatusText(http.StatusOK)}
+	if route.Method != http.MethodHead {
+		schema := &openAPISchema{}
+		if route.Response != nil {
+			schema = g.schemaOf(route.Response)
+		}
+		success.Content = jsonContent(schema)
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = success
+
+	errorRef := &openAPISchema{Ref: "#/components/schemas/" + openAPIErrorSchema}
+	for _, err := range route.Errors {
+		status := strconv.Itoa(err.Status)
+		if _, ok := op.Responses[status]; ok {
+			continue
+		}
+		op.Responses[status] = &openAPIResponse{
+			Description: err.Message,
+			Content:     g.errorContent(errorRef),
+		}
+	}
+	op.Responses["default"] = &openAPIResponse{
+		Description: "Error",
+		Content:     g.errorContent(errorRef),
+	}
+	return op
+}
+
+// requestBodySchema describes the JSON body of a request of type t, excluding
+// any fields that are bound from the URL, querystring or headers. It returns
+// nil if no fields remain.
+func (g *openAPIGenerator) requestBodySchema(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return g.schemaOf(t)
+	}
+	schema := g.objectSchema(t, func(f reflect.StructField) bool {
+		return f.Tag.Get("param") == "" && f.Tag.Get("query") == "" && f.Tag.Get("header") == ""
+	})
+	if len(schema.Properties) == 0 {
+		return nil
+	}
+	return schema
+}
+
+var (
+	typeJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf returns the schema for values of type t. Named struct types are
+// added to the component schemas and referenced.
+func (g *openAPIGenerator) schemaOf(t reflect.Type) *openAPISchema {
+	var nullable bool
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var schema *openAPISchema
+	switch {
+	case t == typeTimeTime:
+		schema = &openAPISchema{Type: "string", Format: "date-time"}
+	case t == typeUUID:
+		schema = &openAPISchema{Type: "string", Format: "uuid"}
+	case t.Implements(typeJSONMarshaler) || reflect.PtrTo(t).Implements(typeJSONMarshaler):
+		schema = &openAPISchema{} // unknown
+	case t.Implements(typeTextMarshaler) || reflect.PtrTo(t).Implements(typeTextMarshaler):
+		schema = &openAPISchema{Type: "string"}
+	default:
+		schema = g.kindSchema(t)
+	}
+	if nullable && schema.Ref == "" {
+		schema.Nullable = true
+	}
+	return schema
+}
+
+// kindSchema returns the schema for t based on its kind.
+func (g *openAPIGenerator) kindSchema(t reflect.Type) *openAPISchema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64, reflect.Int, reflect.Uint, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Array:
+		return &openAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.objectSchema(t, nil)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.claimName(t)
+			g.schemas[name] = &openAPISchema{} // placeholder for recursive types
+			g.schemas[name] = g.objectSchema(t, nil)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	default:
+		return &openAPISchema{} // any value
+	}
+}
+
+// objectSchema returns an inline object schema describing the JSON fields of
+// the struct type t. If include is non-nil, only the fields for which it
+// returns true are described. Fields that are not omitempty are required.
+func (g *openAPIGenerator) objectSchema(t reflect.Type, include func(reflect.StructField) bool) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	g.addProperties(schema, t, include)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// addProperties adds the JSON fields of the struct type t to schema,
+// flattening embedded structs as encoding/json does.
+func (g *openAPIGenerator) addProperties(schema *openAPISchema, t reflect.Type, include func(reflect.StructField) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		if name == "" && f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addProperties(schema, ft, include)
+				continue
+			}
+		}
+		if f.PkgPath != "" || (include != nil && !include(f)) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		var omitempty, asString bool
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "omitempty":
+				omitempty = true
+			case "string":
+				asString = true
+			}
+		}
+		if asString {
+			schema.Properties[name] = &openAPISchema{Type: "string"}
+		} else {
+			schema.Properties[name] = g.schemaOf(f.Type)
+		}
+		if !omitempty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// claimName returns a component name for the named type t that no other type
+// holds. Types sharing a name, such as types declared in different packages
+// or functions, are told apart by a numeric suffix, e.g. Item2.
+func (g *openAPIGenerator) claimName(t reflect.Type) string {
+	base := openAPISchemaName(t)
+	name := base
+	for i := 2; g.claimed[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.claimed[name] = t
+	g.names[t] = name
+	return name
+}
+
+// openAPISchemaName returns the component name for the named type t.
+func openAPISchemaName(t reflect.Type) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, t.Name())
+}
+
+// taggedFields returns the fields of the struct type t that carry a `param`,
+// `query` or `header` tag, keyed by tag and then by name.
+func taggedFields(t reflect.Type) map[string]map[string]reflect.StructField {
+	fields := map[string]map[string]reflect.StructField{
+		"param":  {},
+		"query":  {},
+		"header": {},
+	}
+	if t == nil {
+		return fields
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		for tag := range fields {
+			name := strings.Split(f.Tag.Get(tag), ",")[0]
+			if name != "" && name != "-" {
+				fields[tag][name] = f
+			}
+		}
+	}
+	return fields
+}
+
+// methodHasBody reports whether requests with the given method are expected
+// to carry a body.
+func methodHasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// jsonContent returns a content map describing a JSON value with the given
+// schema.
+func jsonContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// errorContent returns a content map describing an error response with the
+// given schema.
+func (g *openAPIGenerator) errorContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{g.errorContentType: {Schema: schema}}
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]reflect.StructField) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
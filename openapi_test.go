@@ -0,0 +1,222 @@
+package jsonrest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type openAPIUser struct {
+	ID      int          `json:"id"`
+	Name    string       `json:"name"`
+	Manager *openAPIUser `json:"manager,omitempty"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	type updateUserInput struct {
+		ID     int    `param:"id"`
+		Notify bool   `query:"notify"`
+		Name   string `json:"name"`
+	}
+
+	r := jsonrest.NewRouter()
+	r.Get("/users/:id", nopEndpoint,
+		jsonrest.WithSummary("Get a user"),
+		jsonrest.WithResponse(openAPIUser{}),
+		jsonrest.WithErrors(jsonrest.NotFound("user not found")),
+	)
+	r.Post("/users/:id", nopEndpoint,
+		jsonrest.WithRequest(updateUserInput{}),
+		jsonrest.WithResponse(&openAPIUser{}),
+	)
+	r.Get("/health", nopEndpoint, jsonrest.Undocumented())
+
+	b, err := json.Marshal(r.OpenAPI(jsonrest.OpenAPIInfo{Title: "Users", Version: "1.0.0"}))
+	assert.Must(t, err)
+
+	errorContent := m{"application/json": m{"schema": m{"$ref": "#/components/schemas/Error"}}}
+	userContent := m{"application/json": m{"schema": m{"$ref": "#/components/schemas/openAPIUser"}}}
+	assert.JSONEqual(t, string(b), m{
+		"openapi": "3.0.3",
+		"info":    m{"title": "Users", "version": "1.0.0"},
+		"paths": m{
+			"/users/{id}": m{
+				"get": m{
+					"summary": "Get a user",
+					"parameters": []m{
+						{"name": "id", "in": "path", "required": true, "schema": m{"type": "string"}},
+					},
+					"responses": m{
+						"200":     m{"description": "OK", "content": userContent},
+						"404":     m{"description": "user not found", "content": errorContent},
+						"default": m{"description": "Error", "content": errorContent},
+					},
+				},
+				"post": m{
+					"parameters": []m{
+						{"name": "id", "in": "path", "required": true, "schema": m{"type": "integer", "format": "int64"}},
+						{"name": "notify", "in": "query", "schema": m{"type": "boolean"}},
+					},
+					"requestBody": m{
+						"required": true,
+						"content": m{"application/json": m{"schema": m{
+							"type":       "object",
+							"properties": m{"name": m{"type": "string"}},
+							"required":   []string{"name"},
+						}}},
+					},
+					"responses": m{
+						"200":     m{"description": "OK", "content": userContent},
+						"default": m{"description": "Error", "content": errorContent},
+					},
+				},
+			},
+		},
+		"components": m{
+			"schemas": m{
+				"Error": m{
+					"type": "object",
+					"properties": m{
+						"error": m{
+							"type": "object",
+							"properties": m{
+								"code":    m{"type": "string"},
+								"message": m{"type": "string"},
+								"details": m{"type": "array", "items": m{"type": "string"}},
+								"fields":  m{"type": "array", "items": m{"$ref": "#/components/schemas/FieldError"}},
+							},
+							"required": []string{"code", "message"},
+						},
+					},
+					"required": []string{"error"},
+				},
+				"FieldError": m{
+					"type": "object",
+					"properties": m{
+						"field":   m{"type": "string"},
+						"code":    m{"type": "string"},
+						"message": m{"type": "string"},
+						"meta":    m{"type": "object", "additionalProperties": m{}},
+					},
+					"required": []string{"code", "field", "message"},
+				},
+				"openAPIUser": m{
+					"type": "object",
+					"properties": m{
+						"id":      m{"type": "integer", "format": "int64"},
+						"name":    m{"type": "string"},
+						"manager": m{"$ref": "#/components/schemas/openAPIUser"},
+					},
+					"required": []string{"id", "name"},
+				},
+			},
+		},
+	})
+}
+
+func TestOpenAPISchemaNames(t *testing.T) {
+	r := jsonrest.NewRouter()
+	{
+		type Item struct {
+			A int `json:"a"`
+		}
+		r.Get("/a", nopEndpoint, jsonrest.WithResponse(Item{}))
+	}
+	{
+		type Item struct {
+			B string `json:"b"`
+		}
+		r.Get("/b", nopEndpoint, jsonrest.WithResponse(Item{}))
+	}
+	{
+		type Error struct {
+			Reason string `json:"reason"`
+		}
+		r.Get("/c", nopEndpoint, jsonrest.WithResponse(Error{}))
+	}
+
+	b, err := json.Marshal(r.OpenAPI(jsonrest.OpenAPIInfo{Title: "Items", Version: "1.0.0"}))
+	assert.Must(t, err)
+	var doc struct {
+		Paths map[string]struct {
+			Get struct {
+				Responses map[string]struct {
+					Content map[string]struct {
+						Schema struct {
+							Ref string `json:"$ref"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"responses"`
+			} `json:"get"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.Must(t, json.Unmarshal(b, &doc))
+	ref := func(path string) string {
+		return doc.Paths[path].Get.Responses["200"].Content["application/json"].Schema.Ref
+	}
+	assert.Equal(t, ref("/a"), "#/components/schemas/Item")
+	assert.Equal(t, ref("/b"), "#/components/schemas/Item2")
+	assert.Equal(t, ref("/c"), "#/components/schemas/Error2")
+	assert.JSONEqual(t, string(doc.Components.Schemas["Item2"]), m{
+		"type":       "object",
+		"properties": m{"b": m{"type": "string"}},
+		"required":   []string{"b"},
+	})
+	assert.JSONEqual(t, string(doc.Components.Schemas["Error2"]), m{
+		"type":       "object",
+		"properties": m{"reason": m{"type": "string"}},
+		"required":   []string{"reason"},
+	})
+}
+
+func TestOpenAPIYAML(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/ping", nopEndpoint)
+
+	b, err := r.OpenAPI(jsonrest.OpenAPIInfo{Title: "Ping", Version: "1"}).YAML()
+	assert.Must(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "openapi: 3.0.3\ninfo:\n  title: Ping\n"))
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.ServeOpenAPI("/openapi.json", jsonrest.OpenAPIInfo{Title: "Ping", Version: "1"})
+	r.Get("/ping", nopEndpoint)
+
+	w := do(r, http.MethodGet, "/openapi.json", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 200)
+
+	var doc struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	assert.Must(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	_, ok := doc.Paths["/ping"]
+	assert.True(t, ok)
+	assert.Equal(t, len(doc.Paths), 1)
+}
+
+func TestRegisteredRoutes(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/a", nopEndpoint)
+	r.Group().Post("/b", nopEndpoint, jsonrest.WithSummary("b"))
+
+	routes := r.RegisteredRoutes()
+	assert.Equal(t, len(routes), 2)
+	assert.Equal(t, routes[0].Method, http.MethodGet)
+	assert.Equal(t, routes[0].Path, "/a")
+	assert.Equal(t, routes[1].Method, http.MethodPost)
+	assert.Equal(t, routes[1].Path, "/b")
+	assert.Equal(t, routes[1].Summary, "b")
+}
+
+func nopEndpoint(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+	return nil, nil
+}
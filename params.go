@@ -0,0 +1,101 @@
+package jsonrest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Param returns the URL parameter with the given name, converted to T. T may
+// be any type supported by typed endpoints, such as int, bool, float64,
+// time.Time, time.Duration or UUID; slices are read from comma-separated
+// values. For example:
+//
+//     id, err := jsonrest.Param[int64](req, "id")
+//
+// If the value cannot be converted, a 400 Bad Request HTTPError naming the
+// parameter and the expected type is returned.
+func Param[T any](r *Request, name string) (T, error) {
+	return convertParam[T]("url", name, []string{r.Param(name)})
+}
+
+// Query returns the querystring parameter with the given name, converted to
+// T, as Param does. If the parameter is absent, the zero value of T is
+// returned; use a pointer type to tell it apart from a given zero value.
+// Slices are read from repeated keys as well as comma-separated values, so
+// ?id=1&id=2 and ?id=1,2 are equivalent.
+func Query[T any](r *Request, name string) (T, error) {
+	return convertParam[T]("query", name, r.req.URL.Query()[name])
+}
+
+// ParamEnum returns the URL parameter with the given name, which must be one
+// of the allowed values. Otherwise, a 400 Bad Request HTTPError is returned.
+func ParamEnum(r *Request, name string, allowed ...string) (string, error) {
+	return enumParam("url", name, r.Param(name), allowed)
+}
+
+// QueryEnum returns the querystring parameter with the given name, which must
+// be one of the allowed values if present. Otherwise, a 400 Bad Request
+// HTTPError is returned.
+func QueryEnum(r *Request, name string, allowed ...string) (string, error) {
+	s := r.Query(name)
+	if s == "" {
+		return "", nil
+	}
+	return enumParam("query", name, s, allowed)
+}
+
+// convertParam converts the raw values of a parameter to T. The kind is used
+// in error messages to describe where the value came from, e.g. "query".
+func convertParam[T any](kind, name string, values []string) (T, error) {
+	var val T
+	if len(values) == 0 {
+		return val, nil
+	}
+	v := reflect.ValueOf(&val).Elem()
+	if isSlice(v.Type()) {
+		values = splitList(values)
+	}
+	if err := setValue(v, values); err != nil {
+		return val, BadRequest(fmt.Sprintf(
+			"invalid %s parameter: %s", kind, bindErrorDetails(name, values, v.Type()),
+		)).Wrap(err)
+	}
+	return val, nil
+}
+
+// enumParam checks that the value of a parameter is one of the allowed values.
+func enumParam(kind, name, s string, allowed []string) (string, error) {
+	for _, a := range allowed {
+		if s == a {
+			return s, nil
+		}
+	}
+	return "", BadRequest(fmt.Sprintf(
+		"invalid %s parameter: cannot unmarshal %q to %q (expected one of %s)",
+		kind, s, name, strings.Join(allowed, ", "),
+	))
+}
+
+// isSlice reports whether t, or the type it points to, is a slice other than
+// a TextUnmarshaler.
+func isSlice(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && !reflect.PtrTo(t).Implements(typeTextUnmarshaler)
+}
+
+// splitList splits comma-separated values, as in ?id=1,2&id=3, skipping
+// empty elements.
+func splitList(values []string) []string {
+	var list []string
+	for _, val := range values {
+		for _, s := range strings.Split(val, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
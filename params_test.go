@@ -0,0 +1,144 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestTypedParams(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/users/:id/:uuid", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		id, err := jsonrest.Param[int64](req, "id")
+		if err != nil {
+			return nil, err
+		}
+		uuid, err := jsonrest.Param[jsonrest.UUID](req, "uuid")
+		if err != nil {
+			return nil, err
+		}
+		expand, err := jsonrest.Query[bool](req, "expand")
+		if err != nil {
+			return nil, err
+		}
+		limit, err := jsonrest.Query[*int](req, "limit")
+		if err != nil {
+			return nil, err
+		}
+		ratio, err := jsonrest.Query[float64](req, "ratio")
+		if err != nil {
+			return nil, err
+		}
+		since, err := jsonrest.Query[time.Time](req, "since")
+		if err != nil {
+			return nil, err
+		}
+		wait, err := jsonrest.Query[time.Duration](req, "wait")
+		if err != nil {
+			return nil, err
+		}
+		tags, err := jsonrest.Query[[]string](req, "tag")
+		if err != nil {
+			return nil, err
+		}
+		sort, err := jsonrest.QueryEnum(req, "sort", "name", "date")
+		if err != nil {
+			return nil, err
+		}
+		return jsonrest.M{
+			"id":     id,
+			"uuid":   uuid,
+			"expand": expand,
+			"limit":  limit,
+			"ratio":  ratio,
+			"since":  since,
+			"wait":   wait.String(),
+			"tags":   tags,
+			"sort":   sort,
+		}, nil
+	})
+	r.Get("/reports/:format", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		format, err := jsonrest.ParamEnum(req, "format", "csv", "pdf")
+		if err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"format": format}, nil
+	})
+
+	const uuid = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+
+	t.Run("valid", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/1/"+uuid+
+			"?expand=true&limit=10&ratio=0.5&since=2020-01-02T03:04:05Z&wait=1m&tag=a,b&tag=c&sort=date", nil, "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"id":     1,
+			"uuid":   uuid,
+			"expand": true,
+			"limit":  10,
+			"ratio":  0.5,
+			"since":  "2020-01-02T03:04:05Z",
+			"wait":   "1m0s",
+			"tags":   []string{"a", "b", "c"},
+			"sort":   "date",
+		})
+	})
+
+	t.Run("absent query parameters", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/1/"+uuid, nil, "")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"id":     1,
+			"uuid":   uuid,
+			"expand": false,
+			"limit":  nil,
+			"ratio":  0,
+			"since":  "0001-01-01T00:00:00Z",
+			"wait":   "0s",
+			"tags":   nil,
+			"sort":   "",
+		})
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/users/x/" + uuid, `invalid url parameter: cannot unmarshal "x" to "id" (expected integer)`},
+		{"/users/1/not-a-uuid", `invalid url parameter: cannot unmarshal "not-a-uuid" to "uuid" (expected uuid)`},
+		{"/users/1/" + uuid + "?expand=maybe", `invalid query parameter: cannot unmarshal "maybe" to "expand" (expected boolean)`},
+		{"/users/1/" + uuid + "?limit=ten", `invalid query parameter: cannot unmarshal "ten" to "limit" (expected integer)`},
+		{"/users/1/" + uuid + "?ratio=half", `invalid query parameter: cannot unmarshal "half" to "ratio" (expected number)`},
+		{"/users/1/" + uuid + "?since=yesterday", `invalid query parameter: cannot unmarshal "yesterday" to "since" (expected time)`},
+		{"/users/1/" + uuid + "?wait=soon", `invalid query parameter: cannot unmarshal "soon" to "wait" (expected duration)`},
+		{"/users/1/" + uuid + "?sort=size", `invalid query parameter: cannot unmarshal "size" to "sort" (expected one of name, date)`},
+		{"/reports/doc", `invalid url parameter: cannot unmarshal "doc" to "format" (expected one of csv, pdf)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := do(r, http.MethodGet, tt.path, nil, "")
+			assert.Equal(t, w.Result().StatusCode, 400)
+			assert.JSONEqual(t, w.Body.String(), m{
+				"error": m{
+					"code":    "bad_request",
+					"message": tt.want,
+				},
+			})
+		})
+	}
+}
+
+func TestUUID(t *testing.T) {
+	u, err := jsonrest.ParseUUID("F47AC10B-58CC-4372-A567-0E02B2C3D479")
+	assert.Must(t, err)
+	assert.Equal(t, u.String(), "f47ac10b-58cc-4372-a567-0e02b2c3d479")
+
+	for _, s := range []string{"", "f47ac10b58cc4372a5670e02b2c3d479", "f47ac10b-58cc-4372-a567-0e02b2c3d47z"} {
+		_, err := jsonrest.ParseUUID(s)
+		assert.True(t, err != nil)
+	}
+}
@@ -0,0 +1,122 @@
+package jsonrest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// problemContentType is the media type of RFC 7807 problem details documents.
+const problemContentType = "application/problem+json"
+
+// A Problem is an error that is rendered to the client as an RFC 7807 problem
+// details document, with the application/problem+json content type.
+//
+// Endpoints may return a *Problem directly. When a Router is configured with
+// WithProblemDetails, every HTTPError is also converted to a Problem before
+// it is rendered.
+type Problem struct {
+	// Type is a URI reference identifying the problem type. If empty,
+	// "about:blank" is rendered.
+	Type string
+
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+
+	// Status is the HTTP status code.
+	Status int
+
+	// Detail is a human-readable explanation specific to this occurrence of
+	// the problem.
+	Detail string
+
+	// Instance is a URI reference identifying this occurrence of the problem.
+	Instance string
+
+	// Extensions holds additional members to render alongside the standard
+	// ones. Extensions never replace a standard member.
+	Extensions map[string]interface{}
+}
+
+// problemMembers is the JSON representation of the standard members of a
+// Problem.
+type problemMembers struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// StatusCode implements the HTTPErrorResponse interface.
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// Error implements the error interface.
+func (p *Problem) Error() string {
+	return fmt.Sprintf("jsonrest: %v: %v", p.Title, p.Detail)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := problemMembers{
+		Type:     p.Type,
+		Title:    p.Title,
+		Status:   p.Status,
+		Detail:   p.Detail,
+		Instance: p.Instance,
+	}
+	if members.Type == "" {
+		members.Type = "about:blank"
+	}
+	b, err := json.Marshal(members)
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	for k, v := range p.Extensions {
+		if _, ok := doc[k]; !ok {
+			doc[k] = v
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// WithProblemDetails is an Option available for NewRouter to render every
+// HTTPError as an RFC 7807 problem details document. The problem type is
+// typeBase followed by the error's Code, or "about:blank" if typeBase is
+// empty; the title is the text of the status code, and the error's Message
+// becomes the detail. The Code, Details and Fields of the error are rendered
+// as the "code", "details" and "fields" extension members.
+func WithProblemDetails(typeBase string) Option {
+	return func(r *Router) {
+		r.problemDetails = true
+		r.problemTypeBase = typeBase
+	}
+}
+
+// problem converts the HTTPError to a Problem describing the given instance.
+func (err *HTTPError) problem(typeBase, instance string) *Problem {
+	p := &Problem{
+		Title:      http.StatusText(err.Status),
+		Status:     err.Status,
+		Detail:     err.Message,
+		Instance:   instance,
+		Extensions: map[string]interface{}{"code": err.Code},
+	}
+	if typeBase != "" {
+		p.Type = typeBase + err.Code
+	}
+	if len(err.Details) > 0 {
+		p.Extensions["details"] = err.Details
+	}
+	if len(err.Fields) > 0 {
+		p.Extensions["fields"] = err.Fields
+	}
+	return p
+}
@@ -0,0 +1,94 @@
+package jsonrest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestProblemDetails(t *testing.T) {
+	r := jsonrest.NewRouter(jsonrest.WithProblemDetails("https://example.com/problems/"))
+	r.Get("/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("user not found").WithFields(
+			jsonrest.NewFieldError("id", "unknown", "no such user"),
+		)
+	})
+	r.Get("/internal", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	r.Get("/custom", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, &jsonrest.Problem{
+			Type:       "https://example.com/problems/out-of-credit",
+			Title:      "You do not have enough credit.",
+			Status:     403,
+			Detail:     "Your current balance is 30, but that costs 50.",
+			Extensions: map[string]interface{}{"balance": 30, "status": 200},
+		}
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/1", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 404)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/problem+json")
+		assert.JSONEqual(t, w.Body.String(), m{
+			"type":     "https://example.com/problems/not_found",
+			"title":    "Not Found",
+			"status":   404,
+			"detail":   "user not found",
+			"instance": "/users/1",
+			"code":     "not_found",
+			"fields":   []m{{"field": "id", "code": "unknown", "message": "no such user"}},
+		})
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/internal", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 500)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"type":     "https://example.com/problems/unknown_error",
+			"title":    "Internal Server Error",
+			"status":   500,
+			"detail":   "an unknown error occurred",
+			"instance": "/internal",
+			"code":     "unknown_error",
+		})
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/missing", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 404)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/problem+json")
+	})
+
+	t.Run("problem with extensions", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/custom", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 403)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"type":    "https://example.com/problems/out-of-credit",
+			"title":   "You do not have enough credit.",
+			"status":  403,
+			"detail":  "Your current balance is 30, but that costs 50.",
+			"balance": 30,
+		})
+	})
+}
+
+func TestProblemWithoutProblemDetails(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, &jsonrest.Problem{Status: 409, Title: "Conflict"}
+	})
+
+	w := do(r, http.MethodGet, "/", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 409)
+	assert.Equal(t, w.Header().Get("Content-Type"), "application/problem+json")
+	assert.JSONEqual(t, w.Body.String(), m{
+		"type":   "about:blank",
+		"title":  "Conflict",
+		"status": 409,
+	})
+}
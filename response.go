@@ -0,0 +1,157 @@
+package jsonrest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// A Response may be returned by an endpoint to control the status code and
+// headers of a successful response, in addition to its body.
+//
+// If Body is nil, no body is written; this is useful for responses such as
+// 204 No Content.
+type Response struct {
+	// Status is the HTTP status code. If zero, 200 OK is used.
+	Status int
+
+	// Header holds additional headers to send with the response.
+	Header http.Header
+
+	// Body is the value to encode in the response body, if any.
+	Body interface{}
+}
+
+// Created returns an HTTP 201 Created response with the given body, whose
+// Location header is set to location.
+func Created(location string, body interface{}) *Response {
+	return (&Response{Status: http.StatusCreated, Body: body}).WithHeader("Location", location)
+}
+
+// Accepted returns an HTTP 202 Accepted response with the given body.
+func Accepted(body interface{}) *Response {
+	return &Response{Status: http.StatusAccepted, Body: body}
+}
+
+// NoContent returns an HTTP 204 No Content response.
+func NoContent() *Response {
+	return &Response{Status: http.StatusNoContent}
+}
+
+// WithHeader sets a header on the response.
+func (resp *Response) WithHeader(key, val string) *Response {
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set(key, val)
+	return resp
+}
+
+// sendResult writes the result of an endpoint to the client. Results of type
+// *Response determine the status code and headers of the response, and those
+// of type *Stream are written as they are produced. Nil pointers of either
+// type are encoded as null, as other nil results are.
+func (r *Router) sendResult(ctx context.Context, w http.ResponseWriter, req *Request, result interface{}) {
+	if _, ok := result.(writtenResponse); ok {
+		return
+	}
+	if s, ok := result.(*Stream); ok && s != nil {
+		r.sendStream(ctx, w, req, http.StatusOK, s)
+		return
+	}
+	resp, ok := result.(*Response)
+	if !ok || resp == nil {
+		r.send(ctx, w, req, http.StatusOK, result)
+		return
+	}
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.Body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	if s, ok := resp.Body.(*Stream); ok && s != nil {
+		r.sendStream(ctx, w, req, status, s)
+		return
+	}
+	r.send(ctx, w, req, status, resp.Body)
+}
+
+// bodyAllowed reports whether a response to the request with the given status
+// may include a body.
+func bodyAllowed(req *http.Request, status int) bool {
+	switch {
+	case req.Method == http.MethodHead:
+		return false
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// statusWriter is an http.ResponseWriter that records the status of the
+// response written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements the http.Flusher interface, if the underlying
+// ResponseWriter does.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface, if the underlying
+// ResponseWriter does, e.g. for WebSocket upgrades by mounted handlers.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("jsonrest: cannot hijack connection: %w", http.ErrNotSupported)
+	}
+	return h.Hijack()
+}
+
+// ReadFrom implements the io.ReaderFrom interface, so that the underlying
+// ResponseWriter can copy files efficiently, e.g. for http.FileServer.
+func (w *statusWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(w.ResponseWriter, src)
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
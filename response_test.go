@@ -0,0 +1,79 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestResponse(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Post("/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Created("/users/1", jsonrest.M{"id": 1}), nil
+	})
+	r.Post("/jobs", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Accepted(jsonrest.M{"id": "job"}).WithHeader("Retry-After", "10"), nil
+	})
+	r.Handle(http.MethodDelete, "/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.NoContent(), nil
+	})
+	r.Get("/status", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return &jsonrest.Response{Status: http.StatusTeapot, Body: jsonrest.M{"tea": true}}, nil
+	})
+	r.Get("/nil", jsonrest.Typed(func(ctx context.Context, r *jsonrest.Request, in struct{}) (*jsonrest.Response, error) {
+		return nil, nil
+	}))
+	r.Get("/nil-stream", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Accepted((*jsonrest.Stream)(nil)), nil
+	})
+	r.Head("/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"id": 1}, nil
+	})
+
+	t.Run("created", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 201)
+		assert.Equal(t, w.Header().Get("Location"), "/users/1")
+		assert.JSONEqual(t, w.Body.String(), m{"id": 1})
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/jobs", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 202)
+		assert.Equal(t, w.Header().Get("Retry-After"), "10")
+		assert.JSONEqual(t, w.Body.String(), m{"id": "job"})
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		w := do(r, http.MethodDelete, "/users/1", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Body.Len(), 0)
+		assert.Equal(t, w.Header().Get("Content-Type"), "")
+	})
+
+	t.Run("custom status", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/status", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 418)
+		assert.JSONEqual(t, w.Body.String(), m{"tea": true})
+	})
+
+	t.Run("head", func(t *testing.T) {
+		w := do(r, http.MethodHead, "/users/1", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+		assert.Equal(t, w.Body.Len(), 0)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/nil", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Body.String(), "null\n")
+
+		w = do(r, http.MethodGet, "/nil-stream", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 202)
+		assert.Equal(t, w.Body.String(), "null\n")
+	})
+}
@@ -0,0 +1,303 @@
+package jsonrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Route describes an endpoint registered on a Router.
+type Route struct {
+	// Method is the HTTP method the route responds to.
+	Method string
+
+	// Path is the route pattern, as returned by Request.Route.
+	Path string
+
+	// Name identifies the route, e.g. "getUser". It is used as the
+	// operationId in generated documentation.
+	Name string
+
+	// Summary is a short, human-readable description of the route.
+	Summary string
+
+	// Description is a longer description of the route, which may contain
+	// Markdown.
+	Description string
+
+	// Tags group the route with related routes in generated documentation.
+	Tags []string
+
+	// Request is the type of the endpoint's input, if known. Fields tagged
+	// with `param` or `query` describe URL and querystring parameters; the
+	// remaining fields describe the JSON request body.
+	Request reflect.Type
+
+	// Response is the type of the endpoint's successful result, if known.
+	Response reflect.Type
+
+	// Errors are the errors the endpoint is documented to return.
+	Errors []*HTTPError
+
+	// Deprecated indicates that clients should no longer use the route.
+	Deprecated bool
+
+	// Undocumented indicates that the route should be omitted from generated
+	// documentation.
+	Undocumented bool
+
+	// Middleware is applied to the route only, after the middleware of the
+	// router and its groups.
+	Middleware []Middleware
+
+	// Timeout, if non-zero, limits the time the endpoint and its middleware
+	// may take: their context is canceled once it has elapsed.
+	Timeout time.Duration
+
+	// MaxBodyBytes, if non-zero, limits the size of the request body.
+	// Reading beyond it fails, and the request is rejected with a 413 error.
+	// It defaults to the limit set with WithMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// BodyReadTimeout, if non-zero, limits the time taken to read the request
+	// body, from the moment the request is routed. Reading beyond it fails,
+	// and the request is rejected with a 408 error. It is set with
+	// WithBodyReadTimeout.
+	BodyReadTimeout time.Duration
+}
+
+// A RouteOption configures a single route when it is registered.
+type RouteOption func(*Route)
+
+// WithName is a RouteOption that names the route, so that URLs to it can be
+// built with Router.URL. Names must be unique within a router and its groups.
+func WithName(name string) RouteOption {
+	return func(r *Route) {
+		r.Name = name
+	}
+}
+
+// WithSummary is a RouteOption that sets a short description of the route.
+func WithSummary(summary string) RouteOption {
+	return func(r *Route) {
+		r.Summary = summary
+	}
+}
+
+// WithDescription is a RouteOption that sets a longer description of the
+// route.
+func WithDescription(description string) RouteOption {
+	return func(r *Route) {
+		r.Description = description
+	}
+}
+
+// WithTags is a RouteOption that tags the route.
+func WithTags(tags ...string) RouteOption {
+	return func(r *Route) {
+		r.Tags = append(r.Tags, tags...)
+	}
+}
+
+// WithRequest is a RouteOption that documents the route's input. The value
+// itself is ignored; only its type is recorded.
+func WithRequest(v interface{}) RouteOption {
+	return func(r *Route) {
+		r.Request = reflect.TypeOf(v)
+	}
+}
+
+// WithResponse is a RouteOption that documents the route's successful result.
+// The value itself is ignored; only its type is recorded.
+func WithResponse(v interface{}) RouteOption {
+	return func(r *Route) {
+		r.Response = reflect.TypeOf(v)
+	}
+}
+
+// WithErrors is a RouteOption that documents the errors a route may return.
+func WithErrors(errs ...*HTTPError) RouteOption {
+	return func(r *Route) {
+		r.Errors = append(r.Errors, errs...)
+	}
+}
+
+// Deprecated is a RouteOption that marks the route as deprecated.
+func Deprecated() RouteOption {
+	return func(r *Route) {
+		r.Deprecated = true
+	}
+}
+
+// Undocumented is a RouteOption that omits the route from generated
+// documentation.
+func Undocumented() RouteOption {
+	return func(r *Route) {
+		r.Undocumented = true
+	}
+}
+
+// WithMiddleware is a RouteOption that applies middleware to the route only,
+// e.g. to require authentication for a single endpoint:
+//
+//     r.Delete("/users/:id", deleteUser, jsonrest.WithMiddleware(requireAdmin))
+func WithMiddleware(ms ...Middleware) RouteOption {
+	return func(r *Route) {
+		r.Middleware = append(r.Middleware, ms...)
+	}
+}
+
+// WithTimeout is a RouteOption that limits the time the route's endpoint and
+// middleware may take. If the endpoint fails because the timeout elapsed, a
+// 503 error is sent.
+func WithTimeout(d time.Duration) RouteOption {
+	return func(r *Route) {
+		r.Timeout = d
+	}
+}
+
+// WithBodyLimit is a RouteOption that limits the size of the route's request
+// bodies to n bytes, overriding any limit set with WithMaxBodyBytes.
+func WithBodyLimit(n int64) RouteOption {
+	return func(r *Route) {
+		r.MaxBodyBytes = n
+	}
+}
+
+// applyRouteMiddleware wraps the endpoint in the route's own middleware.
+func (r *Route) applyRouteMiddleware(e Endpoint) Endpoint {
+	for i := len(r.Middleware) - 1; i >= 0; i-- {
+		e = r.Middleware[i](e)
+	}
+	return e
+}
+
+// applyLimits wraps the endpoint, including all of its middleware, so that
+// the route's timeout and body limits are enforced.
+func (r *Route) applyLimits(e Endpoint) Endpoint {
+	if r.Timeout <= 0 && r.MaxBodyBytes <= 0 && r.BodyReadTimeout <= 0 {
+		return e
+	}
+	timeout, maxBodyBytes, bodyReadTimeout := r.Timeout, r.MaxBodyBytes, r.BodyReadTimeout
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		if maxBodyBytes > 0 {
+			req.req.Body = http.MaxBytesReader(req.responseWriter, req.req.Body, maxBodyBytes)
+		}
+		if bodyReadTimeout > 0 {
+			// Not every ResponseWriter supports deadlines, e.g. in tests;
+			// such bodies are read without one.
+			rc := http.NewResponseController(req.responseWriter)
+			if rc.SetReadDeadline(time.Now().Add(bodyReadTimeout)) == nil {
+				defer func() { _ = rc.SetReadDeadline(time.Time{}) }()
+			}
+		}
+		if timeout <= 0 {
+			return e(ctx, req)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		result, err := e(ctx, req)
+		if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			if _, ok := err.(HTTPErrorResponse); !ok {
+				err = Error(http.StatusServiceUnavailable, "timeout", "request timed out").Wrap(err)
+			}
+		}
+		return result, err
+	}
+}
+
+// URL returns the URL path of the route with the given name, with its
+// parameters replaced by params, which are given as name-value pairs. Values
+// are escaped; those of catch-all parameters may contain slashes. For example:
+//
+//     r.Get("/users/:id", getUser, jsonrest.WithName("getUser"))
+//     u, err := r.URL("getUser", "id", "42") // "/users/42"
+//
+// An error is returned if no route has the name, or if params are missing or
+// unknown.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	route, ok := r.routes.lookup(name)
+	if !ok {
+		return "", fmt.Errorf("jsonrest: no route named %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("jsonrest: odd number of params for route %q", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	segments := strings.Split(route.Path, "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+			continue
+		}
+		val, ok := values[s[1:]]
+		if !ok {
+			return "", fmt.Errorf("jsonrest: missing param %q for route %q", s[1:], name)
+		}
+		delete(values, s[1:])
+		if s[0] == ':' {
+			segments[i] = url.PathEscape(val)
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(val, "/"), "/")
+		for j, part := range parts {
+			parts[j] = url.PathEscape(part)
+		}
+		segments[i] = strings.Join(parts, "/")
+	}
+	for param := range values {
+		return "", fmt.Errorf("jsonrest: unknown param %q for route %q", param, name)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// routeRegistry records the routes registered on a Router and its groups.
+type routeRegistry struct {
+	mu     sync.Mutex
+	routes []*Route
+	named  map[string]*Route
+}
+
+// add records the route. It panics if another route has the same name.
+func (rr *routeRegistry) add(route *Route) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if route.Name != "" {
+		if _, ok := rr.named[route.Name]; ok {
+			panic(fmt.Sprintf("jsonrest: duplicate route name %q", route.Name))
+		}
+		if rr.named == nil {
+			rr.named = make(map[string]*Route)
+		}
+		rr.named[route.Name] = route
+	}
+	rr.routes = append(rr.routes, route)
+}
+
+// lookup returns the route with the given name.
+func (rr *routeRegistry) lookup(name string) (*Route, bool) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	route, ok := rr.named[name]
+	return route, ok
+}
+
+// list returns a copy of the recorded routes, in registration order.
+func (rr *routeRegistry) list() []Route {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	routes := make([]Route, len(rr.routes))
+	for i, route := range rr.routes {
+		routes[i] = *route
+	}
+	return routes
+}
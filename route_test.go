@@ -0,0 +1,252 @@
+package jsonrest_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestRouteOptions(t *testing.T) {
+	var calls []string
+	mw := func(name string) jsonrest.Middleware {
+		return func(next jsonrest.Endpoint) jsonrest.Endpoint {
+			return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+				calls = append(calls, name)
+				return next(ctx, req)
+			}
+		}
+	}
+
+	r := jsonrest.NewRouter()
+	r.Use(mw("router"))
+	r.Get("/public", nopEndpoint)
+	r.Delete("/users/:id", nopEndpoint,
+		jsonrest.WithName("deleteUser"),
+		jsonrest.WithTags("users", "admin"),
+		jsonrest.WithDescription("Deletes a user permanently."),
+		jsonrest.Deprecated(),
+		jsonrest.WithMiddleware(mw("auth"), mw("audit")),
+	)
+	r.Get("/slow", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, jsonrest.WithTimeout(10*time.Millisecond))
+	r.Post("/upload", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var body struct {
+			Data string `json:"data"`
+		}
+		if err := req.BindBody(&body); err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"length": len(body.Data)}, nil
+	}, jsonrest.WithBodyLimit(32))
+
+	t.Run("middleware", func(t *testing.T) {
+		calls = nil
+		do(r, http.MethodDelete, "/users/1", nil, "application/json")
+		assert.Equal(t, calls, []string{"router", "auth", "audit"})
+
+		calls = nil
+		do(r, http.MethodGet, "/public", nil, "application/json")
+		assert.Equal(t, calls, []string{"router"})
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/slow", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 503)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "timeout",
+				"message": "request timed out",
+			},
+		})
+	})
+
+	t.Run("body limit", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/upload", strings.NewReader(`{"data": "small"}`), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{"length": 5})
+
+		body := `{"data": "` + strings.Repeat("x", 64) + `"}`
+		w = do(r, http.MethodPost, "/upload", strings.NewReader(body), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 413)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "request_entity_too_large",
+				"message": "request body exceeds 32 bytes",
+			},
+		})
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		routes := r.RegisteredRoutes()
+		assert.Equal(t, len(routes), 4)
+		route := routes[1]
+		assert.Equal(t, route.Name, "deleteUser")
+		assert.Equal(t, route.Tags, []string{"users", "admin"})
+		assert.Equal(t, route.Description, "Deletes a user permanently.")
+		assert.True(t, route.Deprecated)
+		assert.Equal(t, len(route.Middleware), 2)
+		assert.Equal(t, routes[2].Timeout, 10*time.Millisecond)
+		assert.Equal(t, routes[3].MaxBodyBytes, int64(32))
+	})
+
+	t.Run("openapi", func(t *testing.T) {
+		b, err := json.Marshal(r.OpenAPI(jsonrest.OpenAPIInfo{Title: "Users", Version: "1.0.0"}))
+		assert.Must(t, err)
+		var doc struct {
+			Paths map[string]map[string]struct {
+				OperationID string   `json:"operationId"`
+				Tags        []string `json:"tags"`
+				Description string   `json:"description"`
+				Deprecated  bool     `json:"deprecated"`
+			} `json:"paths"`
+		}
+		assert.Must(t, json.Unmarshal(b, &doc))
+		op := doc.Paths["/users/{id}"]["delete"]
+		assert.Equal(t, op.OperationID, "deleteUser")
+		assert.Equal(t, op.Tags, []string{"users", "admin"})
+		assert.Equal(t, op.Description, "Deletes a user permanently.")
+		assert.True(t, op.Deprecated)
+	})
+}
+
+func TestURL(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/users/:id", nopEndpoint, jsonrest.WithName("getUser"))
+	r.Prefix("/v1").Get("/orgs/:org/files/*path", nopEndpoint, jsonrest.WithName("getFile"))
+
+	tests := []struct {
+		name    string
+		params  []string
+		want    string
+		wantErr string
+	}{
+		{"getUser", []string{"id", "42"}, "/users/42", ""},
+		{"getUser", []string{"id", "a b/c"}, "/users/a%20b%2Fc", ""},
+		{"getFile", []string{"org", "acme", "path", "/docs/read me.txt"}, "/v1/orgs/acme/files/docs/read%20me.txt", ""},
+		{"getUser", nil, "", `jsonrest: missing param "id" for route "getUser"`},
+		{"getUser", []string{"id"}, "", `jsonrest: odd number of params for route "getUser"`},
+		{"getUser", []string{"id", "1", "org", "acme"}, "", `jsonrest: unknown param "org" for route "getUser"`},
+		{"missing", nil, "", `jsonrest: no route named "missing"`},
+	}
+	for _, tt := range tests {
+		got, err := r.URL(tt.name, tt.params...)
+		if tt.wantErr != "" {
+			assert.True(t, err != nil)
+			assert.Equal(t, err.Error(), tt.wantErr)
+			continue
+		}
+		assert.Must(t, err)
+		assert.Equal(t, got, tt.want)
+	}
+}
+
+func TestDuplicateRouteName(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/a", nopEndpoint, jsonrest.WithName("a"))
+	defer func() {
+		assert.Equal(t, recover(), `jsonrest: duplicate route name "a"`)
+	}()
+	r.Get("/b", nopEndpoint, jsonrest.WithName("a"))
+}
+
+func TestBodyLimits(t *testing.T) {
+	bind := func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var body struct {
+			Data string `json:"data"`
+		}
+		if err := req.BindBody(&body); err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"length": len(body.Data)}, nil
+	}
+	r := jsonrest.NewRouter(jsonrest.WithMaxBodyBytes(16))
+	r.Post("/small", bind)
+	r.Post("/large", bind, jsonrest.WithBodyLimit(1024))
+	r.Post("/upload", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		_, _, err := req.FormFile("file", 1024)
+		return nil, err
+	})
+	body := `{"data": "` + strings.Repeat("x", 32) + `"}`
+
+	t.Run("router limit", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/small", strings.NewReader(body), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 413)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "request_entity_too_large",
+				"message": "request body exceeds 16 bytes",
+			},
+		})
+	})
+
+	t.Run("route limit", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/large", strings.NewReader(body), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{"length": 32})
+	})
+
+	t.Run("multipart form", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		mw := multipart.NewWriter(buf)
+		fw, err := mw.CreateFormFile("file", "test")
+		assert.Must(t, err)
+		_, err = fw.Write(bytes.Repeat([]byte("x"), 64))
+		assert.Must(t, err)
+		assert.Must(t, mw.Close())
+
+		w := do(r, http.MethodPost, "/upload", buf, mw.FormDataContentType())
+		assert.Equal(t, w.Result().StatusCode, 413)
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		routes := r.RegisteredRoutes()
+		assert.Equal(t, routes[0].MaxBodyBytes, int64(16))
+		assert.Equal(t, routes[1].MaxBodyBytes, int64(1024))
+	})
+}
+
+func TestBodyReadTimeout(t *testing.T) {
+	r := jsonrest.NewRouter(jsonrest.WithBodyReadTimeout(50 * time.Millisecond))
+	r.Post("/", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var body jsonrest.M
+		if err := req.BindBody(&body); err != nil {
+			return nil, err
+		}
+		return body, nil
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	assert.Must(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\n{\"a\":")
+	assert.Must(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.Must(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, 408)
+	b, err := io.ReadAll(resp.Body)
+	assert.Must(t, err)
+	assert.JSONEqual(t, string(b), m{
+		"error": m{
+			"code":    "request_timeout",
+			"message": "timed out reading request body",
+		},
+	})
+}
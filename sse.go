@@ -0,0 +1,269 @@
+package jsonrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventStreamMediaType is the media type of Server-Sent Events.
+const eventStreamMediaType = "text/event-stream"
+
+// An Event is a Server-Sent Event, sent with EventStream.Send.
+type Event struct {
+	// ID identifies the event. Clients send the ID of the last event they
+	// received in the Last-Event-ID header when they reconnect.
+	ID string
+
+	// Name is the type of the event. If empty, clients dispatch it as a
+	// "message" event.
+	Name string
+
+	// Data is the payload of the event, which is encoded as JSON. Events
+	// without data are not dispatched by browsers, but still update the last
+	// event ID.
+	Data interface{}
+
+	// Retry, if positive, tells the client how long to wait before
+	// reconnecting once the connection is lost.
+	Retry time.Duration
+}
+
+// An SSEHandler sends Server-Sent Events to the client through stream, until
+// it returns. It should return once ctx is done, e.g. because the client has
+// disconnected.
+type SSEHandler func(ctx context.Context, r *Request, stream *EventStream) error
+
+// An SSEOption configures an endpoint created with SSE.
+type SSEOption func(*sseConfig)
+
+type sseConfig struct {
+	heartbeat time.Duration
+	retry     time.Duration
+}
+
+// Heartbeat sends a comment to the client at the given interval, so that
+// idle connections are not closed by proxies. By default, no heartbeats are
+// sent.
+func Heartbeat(d time.Duration) SSEOption {
+	return func(c *sseConfig) { c.heartbeat = d }
+}
+
+// RetryInterval tells clients how long to wait before reconnecting once the
+// connection is lost, when the stream begins.
+func RetryInterval(d time.Duration) SSEOption {
+	return func(c *sseConfig) { c.retry = d }
+}
+
+// SSE returns an Endpoint that sends Server-Sent Events (text/event-stream)
+// with the given handler. Like any other endpoint, it is registered with
+// Router.Handle or a shortcut such as Router.Get, and runs within the
+// router's middleware:
+//
+//     r.Get("/orders/:id/events", jsonrest.SSE(func(ctx context.Context, r *jsonrest.Request, stream *jsonrest.EventStream) error {
+//         updates := subscribe(ctx, r.Param("id"), stream.LastEventID())
+//         for {
+//             select {
+//             case u := <-updates:
+//                 if err := stream.Send(jsonrest.Event{ID: u.ID, Name: "update", Data: u}); err != nil {
+//                     return err
+//                 }
+//             case <-ctx.Done():
+//                 return nil
+//             }
+//         }
+//     }, jsonrest.Heartbeat(15*time.Second)))
+//
+// The response begins with the first event or heartbeat, so until then the
+// handler may return an error that is sent as for any other endpoint. Errors
+// returned afterwards are sent as an "error" event, rendered by the router's
+// ErrorRenderer, unless the client has disconnected. A panic in the handler
+// once the response has begun is logged, and the connection aborted with
+// http.ErrAbortHandler.
+func SSE(h SSEHandler, opts ...SSEOption) Endpoint {
+	var c sseConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		s := &EventStream{
+			ctx:    ctx,
+			w:      req.responseWriter,
+			rc:     http.NewResponseController(req.responseWriter),
+			codec:  req.router.codecs[defaultMediaType],
+			retry:  c.retry,
+			lastID: req.Header("Last-Event-ID"),
+		}
+		if jc, ok := s.codec.(JSONCodec); ok {
+			jc.Indent = "" // data must fit on a line
+			s.codec = jc
+		}
+		stop := s.startHeartbeat(ctx, c.heartbeat)
+		// If h panics, the heartbeat is stopped before the panic is recovered,
+		// so that nothing more is written to the response.
+		defer stop()
+		err := h(ctx, req, s)
+		stop()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeErr := s.err
+		switch {
+		case s.err != nil, ctx.Err() != nil:
+			// The stream can no longer be written to, or the client is gone.
+		case err != nil && !s.started:
+			return nil, err
+		case err != nil:
+			_, body := req.router.renderError(ctx, req, err)
+			writeErr = s.send(Event{Name: "error", Data: body})
+		case !s.started:
+			writeErr = s.write(nil)
+		}
+		if writeErr != nil {
+			req.router.writeFailed(ctx, req, http.StatusOK, fmt.Errorf("jsonrest: cannot write event stream: %w", writeErr))
+		}
+		return writtenResponse{}, nil
+	}
+}
+
+// An EventStream writes Server-Sent Events to the client. It is safe for
+// concurrent use.
+type EventStream struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	codec   Codec
+	retry   time.Duration
+	lastID  string
+	started bool
+	err     error // first error writing the response
+	buf     bytes.Buffer
+}
+
+// LastEventID returns the ID of the last event the client received, as sent
+// in the Last-Event-ID header when it reconnects, so that the stream can be
+// resumed after it. It is empty for new clients.
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Send writes the event to the client and flushes it. It fails once the
+// request's context is done, or if the event cannot be written, e.g. because
+// the client has disconnected.
+func (s *EventStream) Send(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n\x00") || strings.ContainsAny(e.Name, "\r\n") {
+		return fmt.Errorf("jsonrest: invalid event id %q or name %q", e.ID, e.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.send(e)
+}
+
+// send writes the event, with s.mu held.
+func (s *EventStream) send(e Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.buf.Reset()
+	if e.ID != "" {
+		s.buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Name != "" {
+		s.buf.WriteString("event: " + e.Name + "\n")
+	}
+	if e.Retry > 0 {
+		s.buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	if e.Data != nil {
+		s.buf.WriteString("data: ")
+		if err := s.codec.Encode(&s.buf, e.Data); err != nil {
+			return fmt.Errorf("jsonrest: cannot encode event data: %w", err)
+		}
+		if !bytes.HasSuffix(s.buf.Bytes(), []byte("\n")) {
+			s.buf.WriteByte('\n')
+		}
+	}
+	s.buf.WriteByte('\n')
+	return s.write(s.buf.Bytes())
+}
+
+// startHeartbeat writes a comment to the client at every interval d, if
+// positive, until the returned function is called or ctx is done.
+func (s *EventStream) startHeartbeat(ctx context.Context, d time.Duration) (stop func()) {
+	if d <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				err := s.write([]byte(": heartbeat\n\n"))
+				s.mu.Unlock()
+				if err != nil {
+					return
+				}
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// begin writes the header of the response.
+func (s *EventStream) begin() {
+	s.started = true
+	h := s.w.Header()
+	h.Set("content-type", eventStreamMediaType)
+	h.Set("cache-control", "no-cache")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// write writes b to the client, beginning the response with the retry
+// interval, if any, when needed. It flushes the response and records the
+// first error.
+func (s *EventStream) write(b []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if !s.started {
+		s.begin()
+		if s.retry > 0 {
+			b = append([]byte("retry: "+strconv.FormatInt(s.retry.Milliseconds(), 10)+"\n\n"), b...)
+		}
+	}
+	if len(b) > 0 {
+		if _, err := s.w.Write(b); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = err
+	}
+	return s.err
+}
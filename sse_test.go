@@ -0,0 +1,194 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestSSE(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Use(func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			req.SetResponseHeader("X-Middleware", "true")
+			return next(ctx, req)
+		}
+	})
+	r.Get("/events", jsonrest.SSE(func(ctx context.Context, req *jsonrest.Request, stream *jsonrest.EventStream) error {
+		start := 1
+		if id := stream.LastEventID(); id != "" {
+			n, err := strconv.Atoi(id)
+			if err != nil {
+				return jsonrest.BadRequest("invalid Last-Event-ID")
+			}
+			start = n + 1
+		}
+		for i := start; i <= 3; i++ {
+			if err := stream.Send(jsonrest.Event{ID: strconv.Itoa(i), Name: "count", Data: jsonrest.M{"n": i}}); err != nil {
+				return err
+			}
+		}
+		if req.Query("fail") != "" {
+			return jsonrest.Error(http.StatusInternalServerError, "feed_failed", "feed failed")
+		}
+		return stream.Send(jsonrest.Event{Data: "done", Retry: time.Second})
+	}, jsonrest.RetryInterval(5*time.Second)))
+	get := func(path, lastEventID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("events", func(t *testing.T) {
+		w := get("/events", "")
+		assert.Equal(t, w.Code, 200)
+		assert.Equal(t, w.Header().Get("Content-Type"), "text/event-stream")
+		assert.Equal(t, w.Header().Get("Cache-Control"), "no-cache")
+		assert.Equal(t, w.Header().Get("X-Middleware"), "true")
+		assert.True(t, w.Flushed)
+		assert.Equal(t, w.Body.String(), "retry: 5000\n\n"+
+			"id: 1\nevent: count\ndata: {\"n\":1}\n\n"+
+			"id: 2\nevent: count\ndata: {\"n\":2}\n\n"+
+			"id: 3\nevent: count\ndata: {\"n\":3}\n\n"+
+			"retry: 1000\ndata: \"done\"\n\n")
+	})
+
+	t.Run("resume after last event id", func(t *testing.T) {
+		w := get("/events", "2")
+		assert.Equal(t, w.Code, 200)
+		assert.Equal(t, w.Body.String(), "retry: 5000\n\n"+
+			"id: 3\nevent: count\ndata: {\"n\":3}\n\n"+
+			"retry: 1000\ndata: \"done\"\n\n")
+	})
+
+	t.Run("error before the first event", func(t *testing.T) {
+		w := get("/events", "x")
+		assert.Equal(t, w.Code, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{"code": "bad_request", "message": "invalid Last-Event-ID"},
+		})
+	})
+
+	t.Run("error after the first event", func(t *testing.T) {
+		w := get("/events?fail=1", "2")
+		assert.Equal(t, w.Code, 200)
+		assert.Equal(t, w.Body.String(), "retry: 5000\n\n"+
+			"id: 3\nevent: count\ndata: {\"n\":3}\n\n"+
+			"event: error\ndata: {\"error\":{\"code\":\"feed_failed\",\"message\":\"feed failed\"}}\n\n")
+	})
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/events", jsonrest.SSE(func(ctx context.Context, req *jsonrest.Request, stream *jsonrest.EventStream) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, jsonrest.Heartbeat(10*time.Millisecond)))
+
+	w := do(r, http.MethodGet, "/events", nil, "")
+	assert.Equal(t, w.Code, 200)
+	assert.True(t, strings.HasPrefix(w.Body.String(), ": heartbeat\n\n"))
+}
+
+func TestSSEClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := jsonrest.NewRouter()
+	r.Get("/events", jsonrest.SSE(func(ctx context.Context, req *jsonrest.Request, stream *jsonrest.EventStream) error {
+		for i := 1; ; i++ {
+			if err := stream.Send(jsonrest.Event{Data: i}); err != nil {
+				return err
+			}
+			if i == 2 {
+				cancel()
+			}
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), "data: 1\n\ndata: 2\n\n")
+}
+
+func TestSSEPanic(t *testing.T) {
+	var buf bytes.Buffer
+	r := jsonrest.NewRouter(jsonrest.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	var hookErr error
+	var hookStatus int
+	r.OnError = func(ctx context.Context, req *jsonrest.Request, err error, status int) {
+		hookErr, hookStatus = err, status
+	}
+	r.Get("/events", jsonrest.SSE(func(ctx context.Context, req *jsonrest.Request, stream *jsonrest.EventStream) error {
+		if err := stream.Send(jsonrest.Event{Data: 1}); err != nil {
+			return err
+		}
+		time.Sleep(30 * time.Millisecond)
+		panic("boom")
+	}, jsonrest.Heartbeat(time.Millisecond)))
+
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			assert.Equal(t, recover(), http.ErrAbortHandler)
+		}()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	}()
+	assert.Equal(t, w.Code, 200)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "data: 1\n\n: heartbeat\n\n"))
+	assert.False(t, strings.Contains(w.Body.String(), "error"))
+
+	records := logRecords(t, &buf)
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0]["msg"], "panic serving request")
+	assert.Equal(t, records[0]["panic"], "boom")
+
+	panicErr, ok := hookErr.(*jsonrest.PanicError)
+	assert.True(t, ok)
+	assert.Equal(t, panicErr.Value, "boom")
+	assert.Equal(t, hookStatus, 200)
+}
+
+func TestSSEErrorEventFailure(t *testing.T) {
+	var buf bytes.Buffer
+	r := jsonrest.NewRouter(
+		jsonrest.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		jsonrest.WithErrorRenderer(func(ctx context.Context, r *jsonrest.Request, err error) (int, interface{}) {
+			return http.StatusInternalServerError, make(chan int)
+		}),
+	)
+	r.Get("/events", jsonrest.SSE(func(ctx context.Context, req *jsonrest.Request, stream *jsonrest.EventStream) error {
+		if err := stream.Send(jsonrest.Event{Data: 1}); err != nil {
+			return err
+		}
+		return errors.New("feed failed")
+	}))
+
+	w := do(r, http.MethodGet, "/events", nil, "")
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), "data: 1\n\n")
+
+	var failures []string
+	for _, record := range logRecords(t, &buf) {
+		if record["msg"] == "error serving request" {
+			failures = append(failures, record["error"].(string))
+		}
+	}
+	assert.Equal(t, len(failures), 1)
+	assert.True(t, strings.HasPrefix(failures[0], "jsonrest: cannot write event stream: jsonrest: cannot encode event data:"))
+}
@@ -0,0 +1,267 @@
+package jsonrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ndjsonMediaType is the media type of newline-delimited JSON streams.
+const ndjsonMediaType = "application/x-ndjson"
+
+// A Stream may be returned by an endpoint, directly or as the Body of a
+// Response, to write a sequence of values to the client as they are
+// produced, rather than encoding them all at once. This suits large results,
+// such as exports, which need not be held in memory.
+//
+// A Stream is written as a JSON array or, if the client prefers it in its
+// Accept header, as newline-delimited JSON (application/x-ndjson), one value
+// per line. Each value is flushed to the client as soon as it is written.
+//
+// If the stream fails before its first value, the error is sent as for any
+// other endpoint. Once values have been written, the status can no longer
+// change, so the error rendered by the router's ErrorRenderer is written as a
+// trailing value instead; JSON arrays are then left unterminated, so that
+// clients cannot mistake the truncated stream for a complete one. A panic in
+// the producer after the first value is logged, and the connection aborted
+// with http.ErrAbortHandler.
+//
+// Streams are produced after the endpoint returns, so the timeouts of
+// WithTimeout do not apply to them.
+type Stream struct {
+	produce func(ctx context.Context, send func(v interface{}) error) error
+}
+
+// NewStream returns a Stream whose values are produced by fn, which passes
+// each of them to send in turn. For example:
+//
+//     return jsonrest.NewStream(func(ctx context.Context, send func(interface{}) error) error {
+//         rows, err := db.QueryContext(ctx, "SELECT id, name FROM users")
+//         if err != nil {
+//             return err
+//         }
+//         defer rows.Close()
+//         for rows.Next() {
+//             var u User
+//             if err := rows.Scan(&u.ID, &u.Name); err != nil {
+//                 return err
+//             }
+//             if err := send(u); err != nil {
+//                 return err
+//             }
+//         }
+//         return rows.Err()
+//     }), nil
+//
+// The context is that of the request. send fails once it is done or the
+// response cannot be written, e.g. because the client has gone away, and fn
+// should then return the error.
+func NewStream(fn func(ctx context.Context, send func(v interface{}) error) error) *Stream {
+	return &Stream{produce: fn}
+}
+
+// StreamChan returns a Stream of the values received from ch, until it is
+// closed. If errc is not nil, the stream then fails with the error received
+// from it, so errc must receive one value, or be closed, after ch is closed.
+//
+// The stream stops receiving when the request's context is done, so the
+// producer should stop sending on ch by then, to avoid blocking forever.
+func StreamChan[T any](ch <-chan T, errc <-chan error) *Stream {
+	return NewStream(func(ctx context.Context, send func(v interface{}) error) error {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok {
+					return recvError(ctx, errc)
+				}
+				if err := send(v); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// recvError receives the error that ended a StreamChan from errc, if any.
+func recvError(ctx context.Context, errc <-chan error) error {
+	if errc == nil {
+		return nil
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// streamMediaType returns the media type in which to write a stream for the
+// request: NDJSON if the client prefers it to JSON, according to its Accept
+// header, and JSON otherwise.
+func streamMediaType(req *http.Request) string {
+	for _, mediaRange := range parseAccept(req.Header.Get("Accept")) {
+		switch mediaRange {
+		case ndjsonMediaType:
+			return ndjsonMediaType
+		case defaultMediaType, "application/*", "*/*":
+			return defaultMediaType
+		}
+	}
+	return defaultMediaType
+}
+
+// acceptsStream reports whether the Accept header of the request includes
+// NDJSON or Server-Sent Events, which are written without a Codec.
+func acceptsStream(req *http.Request) bool {
+	for _, mediaRange := range parseAccept(req.Header.Get("Accept")) {
+		if mediaRange == ndjsonMediaType || mediaRange == eventStreamMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// sendStream writes the values of s to the client with the given status.
+func (r *Router) sendStream(ctx context.Context, w http.ResponseWriter, req *Request, status int, s *Stream) {
+	sw := &streamWriter{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		codec:  r.codecs[defaultMediaType],
+		status: status,
+		ndjson: streamMediaType(req.req) == ndjsonMediaType,
+	}
+	if jc, ok := sw.codec.(JSONCodec); ok {
+		jc.Indent = "" // values must fit on a line of NDJSON
+		sw.codec = jc
+	}
+	if !bodyAllowed(req.req, status) {
+		sw.begin()
+		return
+	}
+	err := s.produce(ctx, func(v interface{}) error {
+		return sw.send(ctx, v)
+	})
+	if sw.err == nil {
+		switch {
+		case err != nil && !sw.started:
+			r.sendError(ctx, w, req, err)
+			return
+		case err != nil && ctx.Err() != nil:
+			r.writeFailed(ctx, req, status, fmt.Errorf("jsonrest: stream aborted: %w", err))
+			return
+		case err != nil:
+			_, body := r.renderError(ctx, req, err)
+			sw.fail(body)
+		default:
+			sw.end()
+		}
+	}
+	if sw.err != nil {
+		r.writeFailed(ctx, req, status, fmt.Errorf("jsonrest: cannot write stream: %w", sw.err))
+	}
+}
+
+// A streamWriter writes the values of a Stream as a JSON array or NDJSON.
+// The response is only begun with the first value, so that a stream failing
+// before then can still be answered with an error status.
+type streamWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	codec   Codec
+	status  int
+	ndjson  bool
+	started bool
+	err     error // first error writing the response
+	buf     bytes.Buffer
+}
+
+// begin writes the header of the response.
+func (sw *streamWriter) begin() {
+	contentType := jsonContentType
+	if sw.ndjson {
+		contentType = ndjsonMediaType
+	}
+	sw.w.Header().Set("content-type", contentType)
+	sw.w.WriteHeader(sw.status)
+	sw.started = true
+}
+
+// send writes the value v.
+func (sw *streamWriter) send(ctx context.Context, v interface{}) error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sw.buf.Reset()
+	if err := sw.codec.Encode(&sw.buf, v); err != nil {
+		return fmt.Errorf("jsonrest: cannot encode stream value: %w", err)
+	}
+	return sw.writeValue(sw.buf.Bytes())
+}
+
+// fail writes the rendered error body as the trailing value of the stream.
+// Failures are recorded in sw.err.
+func (sw *streamWriter) fail(body interface{}) {
+	sw.buf.Reset()
+	if err := sw.codec.Encode(&sw.buf, body); err != nil {
+		sw.err = err
+		return
+	}
+	_ = sw.writeValue(sw.buf.Bytes())
+}
+
+// end terminates a complete stream. Failures are recorded in sw.err.
+func (sw *streamWriter) end() {
+	switch {
+	case sw.ndjson && !sw.started:
+		sw.begin()
+	case !sw.started:
+		sw.begin()
+		_ = sw.write([]byte("[]"))
+	case !sw.ndjson:
+		_ = sw.write([]byte("]"))
+	}
+}
+
+// writeValue writes an encoded value, preceded by the opening bracket or a
+// separator in JSON arrays, and flushes it to the client.
+func (sw *streamWriter) writeValue(value []byte) error {
+	value = bytes.TrimRight(value, "\n")
+	var b []byte
+	switch {
+	case sw.ndjson:
+	case sw.started:
+		b = append(b, ',')
+	default:
+		b = append(b, '[')
+	}
+	if !sw.started {
+		sw.begin()
+	}
+	b = append(b, value...)
+	if sw.ndjson {
+		b = append(b, '\n')
+	}
+	return sw.write(b)
+}
+
+// write writes b to the client and flushes it, recording the first error.
+func (sw *streamWriter) write(b []byte) error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if _, err := sw.w.Write(b); err != nil {
+		sw.err = err
+		return err
+	}
+	if err := sw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		sw.err = err
+	}
+	return sw.err
+}
@@ -0,0 +1,198 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestStream(t *testing.T) {
+	count := func(n int, err error) *jsonrest.Stream {
+		return jsonrest.NewStream(func(ctx context.Context, send func(interface{}) error) error {
+			for i := 1; i <= n; i++ {
+				if err := send(jsonrest.M{"n": i}); err != nil {
+					return err
+				}
+			}
+			return err
+		})
+	}
+	r := jsonrest.NewRouter()
+	r.Get("/count", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return count(2, nil), nil
+	})
+	r.Head("/count", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return count(2, nil), nil
+	})
+	r.Get("/empty", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return count(0, nil), nil
+	})
+	r.Get("/fail-early", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return count(0, jsonrest.NotFound("no rows")), nil
+	})
+	r.Get("/fail-late", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return count(2, jsonrest.Error(http.StatusInternalServerError, "export_failed", "export failed")), nil
+	})
+	r.Get("/accepted", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Accepted(count(1, nil)), nil
+	})
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		path, accept string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"/count", "", 200, "application/json; charset=utf-8", `[{"n":1},{"n":2}]`},
+		{"/count", "application/x-ndjson", 200, "application/x-ndjson", "{\"n\":1}\n{\"n\":2}\n"},
+		{"/count", "application/json, application/x-ndjson;q=0.5", 200, "application/json; charset=utf-8", `[{"n":1},{"n":2}]`},
+		{"/empty", "", 200, "application/json; charset=utf-8", `[]`},
+		{"/empty", "application/x-ndjson", 200, "application/x-ndjson", ""},
+		{"/fail-late", "", 200, "application/json; charset=utf-8",
+			`[{"n":1},{"n":2},{"error":{"code":"export_failed","message":"export failed"}}`},
+		{"/fail-late", "application/x-ndjson", 200, "application/x-ndjson",
+			"{\"n\":1}\n{\"n\":2}\n{\"error\":{\"code\":\"export_failed\",\"message\":\"export failed\"}}\n"},
+		{"/accepted", "", 202, "application/json; charset=utf-8", `[{"n":1}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.accept, func(t *testing.T) {
+			w := get(tt.path, tt.accept)
+			assert.Equal(t, w.Code, tt.status)
+			assert.Equal(t, w.Header().Get("Content-Type"), tt.contentType)
+			assert.Equal(t, w.Body.String(), tt.body)
+		})
+	}
+
+	t.Run("failure before the first value", func(t *testing.T) {
+		w := get("/fail-early", "")
+		assert.Equal(t, w.Code, 404)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{"code": "not_found", "message": "no rows"},
+		})
+	})
+
+	t.Run("flushes each value", func(t *testing.T) {
+		w := get("/count", "")
+		assert.True(t, w.Flushed)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		w := do(r, http.MethodHead, "/count", nil, "")
+		assert.Equal(t, w.Code, 200)
+		assert.Equal(t, w.Body.String(), "")
+	})
+}
+
+func TestStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var sendErr error
+	r := jsonrest.NewRouter()
+	r.Get("/", func(_ context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.NewStream(func(ctx context.Context, send func(interface{}) error) error {
+			for i := 1; ; i++ {
+				if err := send(i); err != nil {
+					sendErr = err
+					return err
+				}
+				if i == 2 {
+					cancel()
+				}
+			}
+		}), nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), "[1,2")
+	assert.True(t, errors.Is(sendErr, context.Canceled))
+}
+
+func TestStreamPanic(t *testing.T) {
+	var buf bytes.Buffer
+	r := jsonrest.NewRouter(jsonrest.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	var hookErr error
+	var hookStatus int
+	r.OnError = func(ctx context.Context, req *jsonrest.Request, err error, status int) {
+		hookErr, hookStatus = err, status
+	}
+	r.Get("/", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.NewStream(func(ctx context.Context, send func(interface{}) error) error {
+			for i := 1; i <= 2; i++ {
+				if err := send(i); err != nil {
+					return err
+				}
+			}
+			panic("boom")
+		}), nil
+	})
+
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			assert.Equal(t, recover(), http.ErrAbortHandler)
+		}()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), "[1,2")
+
+	records := logRecords(t, &buf)
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0]["msg"], "panic serving request")
+	assert.Equal(t, records[0]["panic"], "boom")
+
+	panicErr, ok := hookErr.(*jsonrest.PanicError)
+	assert.True(t, ok)
+	assert.Equal(t, panicErr.Value, "boom")
+	assert.Equal(t, hookStatus, 200)
+	assert.Equal(t, records[0]["status"], float64(200))
+}
+
+func TestStreamChan(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Get("/", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		ch := make(chan string)
+		errc := make(chan error, 1)
+		go func() {
+			defer close(errc)
+			defer close(ch)
+			for _, s := range []string{"a", "b", "c"} {
+				select {
+				case ch <- s:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if req.Query("fail") != "" {
+				errc <- jsonrest.BadRequest("failed")
+			}
+		}()
+		return jsonrest.StreamChan(ch, errc), nil
+	})
+
+	w := do(r, http.MethodGet, "/", nil, "")
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), `["a","b","c"]`)
+
+	w = do(r, http.MethodGet, "/?fail=1", nil, "")
+	assert.Equal(t, w.Code, 200)
+	assert.Equal(t, w.Body.String(), `["a","b","c",{"error":{"code":"bad_request","message":"failed"}}`)
+}
@@ -0,0 +1,74 @@
+package jsonrest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// A TypedEndpoint is an endpoint whose input and output types are known at
+// compile time. See Typed.
+type TypedEndpoint[In, Out any] func(ctx context.Context, r *Request, in In) (Out, error)
+
+// Typed adapts a TypedEndpoint into an Endpoint that can be registered on a
+// Router.
+//
+// Before the endpoint is called, a new In is populated from the request: the
+// body is decoded as JSON (if present), then struct fields tagged with
+// `param:"name"` are set from the URL parameters, fields tagged with
+// `query:"name"` from the querystring and fields tagged with `header:"Name"`
+// from the request headers, as described by BindQuery. Conversion failures
+// are reported to the client as a 400 Bad Request. Finally, the input is
+// checked with Validate.
+//
+// For example:
+//
+//     type getUserInput struct {
+//         ID     int  `param:"id"`
+//         Expand bool `query:"expand"`
+//     }
+//
+//     r.Get("/users/:id", jsonrest.Typed(func(ctx context.Context, r *jsonrest.Request, in getUserInput) (*User, error) {
+//         return findUser(ctx, in.ID, in.Expand)
+//     }))
+func Typed[In, Out any](e TypedEndpoint[In, Out]) Endpoint {
+	return func(ctx context.Context, r *Request) (interface{}, error) {
+		var in In
+		if err := r.bindInput(&in); err != nil {
+			return nil, err
+		}
+		return e(ctx, r, in)
+	}
+}
+
+// bindInput populates the value pointed to by dst from the request body, URL
+// parameters, querystring and headers, in that order, then validates it. If
+// dst points to a nil pointer to a struct, a new struct is allocated first.
+func (r *Request) bindInput(dst interface{}) error {
+	if v := reflect.ValueOf(dst).Elem(); v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		dst = v.Interface()
+	}
+	if r.req.ContentLength != 0 {
+		// Bodies of unknown length may turn out to be empty.
+		if err := r.decodeBody(dst); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+	if reflect.TypeOf(dst).Elem().Kind() != reflect.Struct {
+		return Validate(dst)
+	}
+	if err := bindTagged(dst, "param", "url", r.paramLookup()); err != nil {
+		return err
+	}
+	if err := bindTagged(dst, "query", "query", r.queryLookup()); err != nil {
+		return err
+	}
+	if err := bindTagged(dst, "header", "header", r.headerLookup()); err != nil {
+		return err
+	}
+	return Validate(dst)
+}
@@ -0,0 +1,116 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestTyped(t *testing.T) {
+	type input struct {
+		ID     int      `param:"id"`
+		Expand bool     `query:"expand"`
+		Tags   []string `query:"tag"`
+		Name   string   `json:"name"`
+	}
+	type output struct {
+		ID     int      `json:"id"`
+		Expand bool     `json:"expand"`
+		Tags   []string `json:"tags"`
+		Name   string   `json:"name"`
+	}
+	endpoint := jsonrest.Typed(func(ctx context.Context, r *jsonrest.Request, in input) (output, error) {
+		return output(in), nil
+	})
+
+	r := jsonrest.NewRouter()
+	r.Get("/users/:id", endpoint)
+	r.Post("/users/:id", endpoint)
+
+	t.Run("params and query", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/12?expand=true&tag=a&tag=b", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"id":     12,
+			"expand": true,
+			"tags":   []string{"a", "b"},
+			"name":   "",
+		})
+	})
+
+	t.Run("body", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users/12", strings.NewReader(`{"name": "alice"}`), "application/json")
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"id":     12,
+			"expand": false,
+			"tags":   nil,
+			"name":   "alice",
+		})
+	})
+
+	t.Run("bad param", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/abc", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `invalid url parameter: cannot unmarshal "abc" to "id" (expected integer)`,
+			},
+		})
+	})
+
+	t.Run("bad query", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/1?expand=maybe", nil, "application/json")
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "bad_request",
+				"message": `invalid query parameter: cannot unmarshal "maybe" to "expand" (expected boolean)`,
+			},
+		})
+	})
+}
+
+func TestTypedPointer(t *testing.T) {
+	type input struct {
+		ID   int    `param:"id"`
+		Name string `json:"name"`
+	}
+	r := jsonrest.NewRouter()
+	endpoint := jsonrest.Typed(func(ctx context.Context, r *jsonrest.Request, in *input) (jsonrest.M, error) {
+		return jsonrest.M{"id": in.ID, "name": in.Name}, nil
+	})
+	r.Get("/users/:id", endpoint)
+	r.Post("/users/:id", endpoint)
+
+	w := do(r, http.MethodGet, "/users/7", nil, "")
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"id": 7, "name": ""})
+
+	w = do(r, http.MethodPost, "/users/7", strings.NewReader(`{"name": "alice"}`), "application/json")
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"id": 7, "name": "alice"})
+}
+
+func TestTypedEmptyBodyOfUnknownLength(t *testing.T) {
+	type input struct {
+		ID int `param:"id"`
+	}
+	r := jsonrest.NewRouter()
+	r.Post("/users/:id", jsonrest.Typed(func(ctx context.Context, r *jsonrest.Request, in input) (jsonrest.M, error) {
+		return jsonrest.M{"id": in.ID}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users/7", strings.NewReader(""))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"id": 7})
+}
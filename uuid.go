@@ -0,0 +1,59 @@
+package jsonrest
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// A UUID is a universally unique identifier, as defined by RFC 4122. It is
+// encoded in its canonical textual form, e.g.
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479", so it can be used in request bodies
+// and parameters alike.
+type UUID [16]byte
+
+var errInvalidUUID = errors.New("invalid uuid")
+
+// ParseUUID parses a UUID in its canonical textual form. Hexadecimal digits
+// may be upper or lower case.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errInvalidUUID
+	}
+	src := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36])
+	if _, err := hex.Decode(u[:], src); err != nil {
+		return u, errInvalidUUID
+	}
+	return u, nil
+}
+
+// String returns the canonical textual form of the UUID.
+func (u UUID) String() string {
+	b, _ := u.MarshalText()
+	return string(b)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (u UUID) MarshalText() ([]byte, error) {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return b, nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
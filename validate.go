@@ -0,0 +1,424 @@
+package jsonrest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// validators holds the custom validators registered with RegisterValidator,
+// keyed by reflect.Type.
+var validators sync.Map
+
+// RegisterValidator registers a custom validator for values of type T. It is
+// called by Validate for every value of type T encountered, in addition to any
+// `validate` tag rules; a non-nil error is reported as a failure of the
+// field holding the value.
+//
+// RegisterValidator is typically called from an init function.
+func RegisterValidator[T any](fn func(T) error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	validators.Store(t, func(v interface{}) error {
+		return fn(v.(T))
+	})
+	validatable.Range(func(key, _ interface{}) bool {
+		validatable.Delete(key)
+		return true
+	})
+}
+
+// validatable caches, by reflect.Type, whether values of the type can hold
+// anything for Validate to check, so that values which cannot, such as large
+// byte slices, are not walked.
+var validatable sync.Map
+
+// needsValidation reports whether values of type t can reach a `validate`
+// tag or a registered validator.
+func needsValidation(t reflect.Type) bool {
+	if ok, found := validatable.Load(t); found {
+		return ok.(bool)
+	}
+	ok := reachesRules(t, map[reflect.Type]bool{})
+	validatable.Store(t, ok)
+	return ok
+}
+
+// reachesRules computes needsValidation for t. Types in seen are already
+// being computed, so recursive types end there.
+func reachesRules(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if _, ok := validators.Load(t); ok {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		// The dynamic type is only known for each value.
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return reachesRules(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !(f.Anonymous && f.Type.Kind() == reflect.Struct) {
+				continue
+			}
+			tag := f.Tag.Get("validate")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" || reachesRules(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks v against the rules declared in the `validate` struct tags
+// of its fields, recursing into nested structs, pointers and slices, and runs
+// any validators registered with RegisterValidator. Rules are separated by
+// commas:
+//
+//     required       the value must not be the zero value
+//     min=N, max=N   numbers must be within the bound; strings, slices and
+//                    maps must have a length within the bound
+//     len=N          strings, slices and maps must have exactly this length
+//     enum=a|b|c     the value must be one of the listed options
+//     regex=pattern  strings must match the pattern; as the pattern may
+//                    contain commas, this must be the last rule
+//     omitempty      the remaining rules are skipped for zero values
+//
+// Other rules are ignored, so that structs may also carry the tags of another
+// validator, such as go-playground/validator; as the rules following its dive
+// apply to the elements of a slice or map, they are ignored too.
+//
+// Rules other than required are skipped for nil pointers. If any rule fails,
+// Validate returns an UnprocessableEntity HTTPError whose Details describe
+// every failing field by its JSON path, e.g. "nested.count", and whose Fields
+// hold the same failures in structured form, coded by the name of the failing
+// rule ("invalid" for custom validators). Malformed arguments to the rules
+// above are reported as internal errors.
+//
+// BindBody and Typed endpoints call Validate automatically.
+func Validate(v interface{}) error {
+	var vd validation
+	if err := vd.value(reflect.ValueOf(v), nil); err != nil {
+		return err
+	}
+	if len(vd.details) == 0 {
+		return nil
+	}
+	httpErr := UnprocessableEntity("validation failed").WithFields(vd.fields...)
+	httpErr.Details = vd.details
+	return httpErr
+}
+
+// validation accumulates the failures found while validating a value.
+type validation struct {
+	details []string
+	fields  []FieldError
+}
+
+// A fieldPath locates a value within the one being validated, e.g.
+// "items[2].name". It is only formatted when a failure is recorded; the nil
+// path is that of the value itself.
+type fieldPath struct {
+	parent *fieldPath
+	name   string // the name of a field, or "" for an element
+	index  int    // the index of an element
+}
+
+// field returns the path of the named field of the value at p.
+func (p *fieldPath) field(name string) *fieldPath {
+	return &fieldPath{parent: p, name: name}
+}
+
+// elem returns the path of the i'th element of the value at p.
+func (p *fieldPath) elem(i int) *fieldPath {
+	return &fieldPath{parent: p, index: i}
+}
+
+func (p *fieldPath) String() string {
+	if p == nil {
+		return ""
+	}
+	if p.name == "" {
+		return fmt.Sprintf("%s[%d]", p.parent, p.index)
+	}
+	return joinPath(p.parent.String(), p.name)
+}
+
+// fail records a failure of the value at path.
+func (vd *validation) fail(path *fieldPath, fe FieldError) {
+	fe.Field = path.String()
+	vd.fields = append(vd.fields, fe)
+	if fe.Field == "" {
+		vd.details = append(vd.details, fe.Message)
+		return
+	}
+	vd.details = append(vd.details, fmt.Sprintf("%q %s", fe.Field, fe.Message))
+}
+
+// value validates v, found at path, and everything reachable from it.
+func (vd *validation) value(v reflect.Value, path *fieldPath) error {
+	if !v.IsValid() || !needsValidation(v.Type()) {
+		return nil
+	}
+	if fn, ok := validators.Load(v.Type()); ok && v.CanInterface() {
+		if err := fn.(func(interface{}) error)(v.Interface()); err != nil {
+			vd.fail(path, NewFieldError("", "invalid", err.Error()))
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return vd.value(v.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := vd.value(v.Index(i), path.elem(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return vd.structFields(v, path)
+	}
+	return nil
+}
+
+// structFields validates the exported fields of the struct v, found at path.
+func (vd *validation) structFields(v reflect.Value, path *fieldPath) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported; but the fields of an embedded struct are promoted.
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				if err := vd.structFields(v.Field(i), path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		fieldPath := path
+		if name := fieldName(f); name != "" {
+			fieldPath = path.field(name)
+		}
+		if tag != "" {
+			if err := vd.rules(v.Field(i), fieldPath, tag); err != nil {
+				return fmt.Errorf("jsonrest: invalid validate tag on %s.%s: %v", t, f.Name, err)
+			}
+		}
+		if err := vd.value(v.Field(i), fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name by which the field f is known to clients: its
+// JSON name, or else the name it is bound from in the URL or querystring. It
+// returns "" for embedded structs, whose fields are promoted.
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "param", "query", "header"} {
+		if name := strings.Split(f.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	if f.Anonymous {
+		return ""
+	}
+	return f.Name
+}
+
+// joinPath appends name to the field path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// rules applies the rules in tag to v, found at path.
+func (vd *validation) rules(v reflect.Value, path *fieldPath, tag string) error {
+	// A pointer is present if it is non-nil, even if it points to a zero value.
+	isPtr := v.Kind() == reflect.Ptr
+	if isPtr {
+		if v.IsNil() {
+			if hasRule(tag, "required") {
+				vd.fail(path, NewFieldError("", "required", "is required"))
+			}
+			return nil
+		}
+		v = v.Elem()
+	}
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+		switch {
+		case isPtr && name == "required":
+			continue
+		case name == "omitempty":
+			if !isPtr && v.IsZero() {
+				return nil
+			}
+			continue
+		case name == "dive":
+			return nil
+		}
+		msg, err := checkRule(v, name, arg)
+		if err != nil {
+			return err
+		}
+		if msg != "" {
+			vd.fail(path, ruleFieldError(name, arg, msg))
+		}
+	}
+	return nil
+}
+
+// hasRule reports whether the tag contains the named rule.
+func hasRule(tag, name string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFieldError returns a FieldError for the failure of the named rule,
+// carrying the rule's argument as metadata.
+func ruleFieldError(name, arg, msg string) FieldError {
+	fe := NewFieldError("", name, msg)
+	switch name {
+	case "min", "max", "len":
+		fe = fe.WithMeta(name, arg)
+	case "enum":
+		fe = fe.WithMeta("options", strings.Split(arg, "|"))
+	case "regex":
+		fe = fe.WithMeta("pattern", arg)
+	}
+	return fe
+}
+
+// checkRule applies a single rule to v, returning a description of the
+// failure, if any.
+func checkRule(v reflect.Value, name, arg string) (string, error) {
+	switch name {
+	case "required":
+		if v.IsZero() {
+			return "is required", nil
+		}
+	case "min", "max", "len":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "", fmt.Errorf("rule %s: %v", name, err)
+		}
+		n, isLength, ok := measure(v)
+		if !ok {
+			return "", fmt.Errorf("rule %s: unsupported type %s", name, v.Type())
+		}
+		subject := "must be"
+		if isLength {
+			subject = "must have length"
+		}
+		switch {
+		case name == "min" && n < bound:
+			return fmt.Sprintf("%s at least %s", subject, arg), nil
+		case name == "max" && n > bound:
+			return fmt.Sprintf("%s at most %s", subject, arg), nil
+		case name == "len" && n != bound:
+			return fmt.Sprintf("must have length %s", arg), nil
+		}
+	case "enum":
+		options := strings.Split(arg, "|")
+		s := formatValue(v)
+		for _, option := range options {
+			if s == option {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.Join(options, ", "), nil
+	case "regex":
+		re, err := compileRegexp(arg)
+		if err != nil {
+			return "", fmt.Errorf("rule regex: %v", err)
+		}
+		if v.Kind() != reflect.String {
+			return "", fmt.Errorf("rule regex: unsupported type %s", v.Type())
+		}
+		if !re.MatchString(v.String()) {
+			return fmt.Sprintf("must match %q", arg), nil
+		}
+	}
+	// Rules of other validators are ignored.
+	return "", nil
+}
+
+// measure returns the numeric value of v, or its length for strings, slices
+// and maps.
+func measure(v reflect.Value) (n float64, isLength, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
+
+// formatValue returns the string form of the scalar v, for comparison with
+// enum options.
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	}
+	return fmt.Sprint(v)
+}
+
+// regexps caches the compiled patterns of regex rules.
+var regexps sync.Map
+
+// compileRegexp compiles the pattern, caching the result.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,190 @@
+package jsonrest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type evenNumber int
+
+func init() {
+	jsonrest.RegisterValidator(func(n evenNumber) error {
+		if n%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+}
+
+func TestValidate(t *testing.T) {
+	type item struct {
+		SKU string `json:"sku" validate:"required,regex=^[A-Z]{2,4}$"`
+	}
+	type input struct {
+		Name   string     `json:"name" validate:"required,max=5"`
+		Kind   string     `json:"kind" validate:"enum=a|b"`
+		Count  *int       `json:"count" validate:"required,min=1"`
+		Even   evenNumber `json:"even"`
+		Nested struct {
+			Count int `json:"count" validate:"min=1,max=10"`
+		} `json:"nested"`
+		Items []item `json:"items" validate:"max=2"`
+	}
+
+	tests := []struct {
+		body    string
+		details []string
+	}{
+		{
+			body: `{"name": "bob", "kind": "a", "count": 1, "nested": {"count": 3}, "items": [{"sku": "AB"}]}`,
+		},
+		{
+			body: `{"name": "robert", "kind": "c", "even": 3, "nested": {"count": 11}, "items": [{"sku": "AB"}, {"sku": "a,b"}, {}]}`,
+			details: []string{
+				`"name" must have length at most 5`,
+				`"kind" must be one of a, b`,
+				`"count" is required`,
+				`"even" must be even`,
+				`"nested.count" must be at most 10`,
+				`"items" must have length at most 2`,
+				`"items[1].sku" must match "^[A-Z]{2,4}$"`,
+				`"items[2].sku" is required`,
+				`"items[2].sku" must match "^[A-Z]{2,4}$"`,
+			},
+		},
+		{
+			body:    `{"name": "bob", "count": 0, "nested": {"count": 1}}`,
+			details: []string{`"kind" must be one of a, b`, `"count" must be at least 1`},
+		},
+	}
+
+	r := jsonrest.NewRouter()
+	r.Post("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var in input
+		if err := r.BindBody(&in); err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"ok": true}, nil
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			w := do(r, http.MethodPost, "/", strings.NewReader(tt.body), "application/json")
+			if tt.details == nil {
+				assert.Equal(t, w.Result().StatusCode, 200)
+				return
+			}
+			assert.Equal(t, w.Result().StatusCode, 422)
+			var resp struct {
+				Error struct {
+					Details []string `json:"details"`
+				} `json:"error"`
+			}
+			assert.Must(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, resp.Error.Details, tt.details)
+		})
+	}
+}
+
+func TestValidateTyped(t *testing.T) {
+	type input struct {
+		ID    int `param:"id" validate:"min=1"`
+		Limit int `query:"limit" validate:"max=100"`
+	}
+	r := jsonrest.NewRouter()
+	r.Get("/users/:id", jsonrest.Typed(func(ctx context.Context, r *jsonrest.Request, in input) (jsonrest.M, error) {
+		return jsonrest.M{"id": in.ID}, nil
+	}))
+
+	w := do(r, http.MethodGet, "/users/0?limit=500", nil, "application/json")
+	assert.Equal(t, w.Result().StatusCode, 422)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "unprocessable_entity",
+			"message": "validation failed",
+			"details": []string{`"id" must be at least 1`, `"limit" must be at most 100`},
+			"fields": []m{
+				{"field": "id", "code": "min", "message": "must be at least 1", "meta": m{"min": "1"}},
+				{"field": "limit", "code": "max", "message": "must be at most 100", "meta": m{"max": "100"}},
+			},
+		},
+	})
+}
+
+func TestValidateInvalidRule(t *testing.T) {
+	var v struct {
+		Name string `validate:"min=abc"`
+	}
+	err := jsonrest.Validate(&v)
+	assert.True(t, err != nil)
+	_, isHTTPError := err.(*jsonrest.HTTPError)
+	assert.False(t, isHTTPError)
+}
+
+func TestValidateForeignRules(t *testing.T) {
+	type input struct {
+		Email    string   `json:"email" validate:"required,email"`
+		Nickname string   `json:"nickname" validate:"omitempty,min=3"`
+		Tags     []string `json:"tags" validate:"max=2,dive,min=3"`
+	}
+
+	assert.Must(t, jsonrest.Validate(&input{Email: "a@example.com", Tags: []string{"a"}}))
+
+	err := jsonrest.Validate(&input{Nickname: "ab"})
+	httpErr, ok := err.(*jsonrest.HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, httpErr.Details, []string{`"email" is required`, `"nickname" must have length at least 3`})
+}
+
+type node struct {
+	Name     string  `json:"name" validate:"required"`
+	Children []*node `json:"children"`
+}
+
+func TestValidateRecursive(t *testing.T) {
+	tree := &node{Name: "root", Children: []*node{{Name: "a"}, {Children: []*node{{}}}}}
+	httpErr, ok := jsonrest.Validate(tree).(*jsonrest.HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, httpErr.Details, []string{
+		`"children[1].name" is required`,
+		`"children[1].children[0].name" is required`,
+	})
+}
+
+type oddNumber int
+
+func TestValidateLateRegistration(t *testing.T) {
+	var v struct {
+		Odd []oddNumber `json:"odd"`
+	}
+	v.Odd = []oddNumber{2}
+	assert.Must(t, jsonrest.Validate(&v))
+
+	jsonrest.RegisterValidator(func(n oddNumber) error {
+		if n%2 == 0 {
+			return errors.New("must be odd")
+		}
+		return nil
+	})
+	httpErr, ok := jsonrest.Validate(&v).(*jsonrest.HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, httpErr.Details, []string{`"odd[0]" must be odd`})
+}
+
+func BenchmarkValidateBytes(b *testing.B) {
+	v := struct {
+		Data []byte `json:"data"`
+	}{Data: make([]byte, 10<<20)}
+	for i := 0; i < b.N; i++ {
+		if err := jsonrest.Validate(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}